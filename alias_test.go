@@ -0,0 +1,113 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type renameGlobals struct {
+	Region string `alias:"zone"`
+}
+
+type renamedCmd struct {
+	Port    int    `alias:"old-port,listen"`
+	Verbose bool   `hidden:""`
+	Legacy  string `deprecated:"use --port instead"`
+}
+
+func (r *renamedCmd) Aliases() []string { return []string{"start"} }
+func (r *renamedCmd) Run([]string)      {}
+
+type legacyCmd struct{}
+
+func (legacyCmd) Deprecated() string { return "use serve instead" }
+func (legacyCmd) Run([]string)       {}
+
+type secretCmd struct{}
+
+func (secretCmd) Hidden() bool { return true }
+func (secretCmd) Run([]string) {}
+
+type renameRoot struct {
+	g     renameGlobals
+	serve renamedCmd
+}
+
+func (r *renameRoot) Globals() any { return &r.g }
+
+func (r *renameRoot) SubCommands() Map {
+	return Map{"serve": &r.serve, "old": &legacyCmd{}, "secret": &secretCmd{}}
+}
+
+func TestAliases(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		var opts []BindOption
+		if lazy {
+			opts = append(opts, WithLazy())
+		}
+		for name, run := range backendsWith(opts...) {
+			t.Run(name, func(t *testing.T) {
+				root := &renameRoot{}
+				assert.NoError(t, run(t, root, "start", "--old-port", "9", "--zone", "eu"))
+				assert.Equal(t, 9, root.serve.Port)
+				assert.Equal(t, "eu", root.g.Region)
+
+				root = &renameRoot{}
+				assert.NoError(t, run(t, root, "serve", "--listen=10", "secret"))
+				assert.Equal(t, 10, root.serve.Port)
+			})
+		}
+	}
+}
+
+func TestAliasDuplicate(t *testing.T) {
+	type cmd struct {
+		simpleCmd
+		Port int    `alias:"addr"`
+		Addr string `alias:"port"`
+	}
+	_, err := bind("app", &cmd{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "duplicate flag --addr")
+	assert.ErrorContains(t, err, "duplicate flag --port")
+
+	_, err = bind("app", Map{"serve": &renamedCmd{}, "start": &legacyCmd{}}, nil)
+	assert.ErrorContains(t, err, "app: duplicate command start")
+}
+
+func TestDeprecated(t *testing.T) {
+	const want = "Command \"old\" is deprecated, use serve instead\n"
+	const wantFlag = "Flag --legacy has been deprecated, use --port instead\n"
+
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &renameRoot{}, []string{"old"}, WithErrOutput(&out)))
+	assert.Equal(t, want, out.String())
+	out.Reset()
+	assert.NoError(t, Run(context.Background(), "app", &renameRoot{}, []string{"serve", "--legacy", "x"}, WithErrOutput(&out)))
+	assert.Equal(t, wantFlag, out.String())
+
+	out.Reset()
+	fs, run, err := BindFlagSet("app", &renameRoot{})
+	assert.NoError(t, err)
+	fs.SetOutput(&out)
+	assert.NoError(t, fs.Parse([]string{"old"}))
+	assert.NoError(t, run(context.Background()))
+	assert.Equal(t, want, out.String())
+}
+
+func TestHidden(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &renameRoot{}, []string{"--help"}, WithOutput(&out)))
+	assert.Contains(t, out.String(), "serve")
+	assert.NotContains(t, out.String(), "old")
+	assert.NotContains(t, out.String(), "secret")
+
+	out.Reset()
+	assert.NoError(t, Run(context.Background(), "app", &renameRoot{}, []string{"serve", "--help"}, WithOutput(&out)))
+	assert.Contains(t, out.String(), "--port")
+	assert.NotContains(t, out.String(), "--verbose")
+	assert.NotContains(t, out.String(), "--legacy")
+}
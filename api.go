@@ -1,9 +1,8 @@
 package quack
 
 import (
+	"context"
 	"errors"
-
-	"github.com/spf13/cobra"
 )
 
 var (
@@ -11,6 +10,9 @@ var (
 	ErrInvalidType = errors.New("invlaid type")
 	// ErrNotACommand will be returned when a binding target doesn't implement one of the command interfaces.
 	ErrNotACommand = errors.New("not a command")
+	// ErrInvalidOption will be returned when the struct tags or field types of options are invalid.
+	// The error lists every problem found in the command tree.
+	ErrInvalidOption = errors.New("invalid options")
 )
 
 // Command is a runnable command that doesn't have sub commands
@@ -23,10 +25,15 @@ type SimpleCommand interface {
 	Run()
 }
 
-// CobraCommand is the a comand that implements the cobra.Command.Run interface.
-// This is useful when you need lower level access to things like global options or the raw cli args.
-type CobraCommand interface {
-	Run(cmd *cobra.Command, args []string)
+// CommandE is a command that receives the framework's context and can fail.
+// Errors returned from RunE are propagated to the caller of Execute/Run.
+type CommandE interface {
+	RunE(ctx context.Context, args []string) error
+}
+
+// ContextCommand is a command that doesn't care about the raw args, but receives the framework's context and can fail.
+type ContextCommand interface {
+	RunContext(ctx context.Context) error
 }
 
 // UrfaveCommand is a command that implements the urfave/cli v3 ActionFunc interface.
@@ -44,16 +51,99 @@ type Group interface {
 	SubCommands() Map
 }
 
+// OrderedGroup is a group whose subcommands are listed in help in the given order, rather than by name.
+type OrderedGroup interface {
+	OrderedSubCommands() []Entry
+}
+
+// Entry is a subcommand of an OrderedGroup.
+type Entry struct {
+	Name    string
+	Command any
+	// Category is the heading the command is listed under in help.
+	// It overrides the `category` tag of the command.
+	Category string
+}
+
+// Aliaser is a command that can also be run by other names, such as the names it had before being renamed.
+type Aliaser interface {
+	Aliases() []string
+}
+
+// Hider is a command that is left out of help. It can still be run.
+type Hider interface {
+	Hidden() bool
+}
+
+// Deprecator is a command on its way out. It is left out of help, and running it prints
+// a warning with the returned message, unless the message is empty.
+type Deprecator interface {
+	Deprecated() string
+}
+
+// Globaler is a group that declares options shared by every command below it.
+// Globals must return a pointer to a struct, whose fields become persistent options of the group.
+// Commands can reach the parsed globals through a field of the same type (or a pointer to it),
+// or with GetGlobals.
+type Globaler interface {
+	Globals() any
+}
+
+// BeforeHook is a command or group that sets up state before a command runs.
+// Before hooks run from the root to the command being run, after all options are parsed.
+// An error aborts the command and is reported with the path of the failing command.
+type BeforeHook interface {
+	Before(ctx context.Context) error
+}
+
+// AfterHook is a command or group that tears down state after a command runs.
+// After hooks run from the command being run to the root, each receiving the error returned
+// by the command (or by the previous After hook), and returning the error to report.
+// They don't run if a Before hook failed.
+type AfterHook interface {
+	After(ctx context.Context, err error) error
+}
+
+// Completer suggests values for shell completion.
+// It can be implemented by the type of an option (including the elements of a slice),
+// or by a command to complete its positional arguments.
+type Completer interface {
+	Complete(ctx context.Context, partial string) []string
+}
+
+// Choicer is the type of an option that takes one of a fixed set of values, like the `enum` tag.
+// Values are checked as they are parsed, listed in help and offered by shell completion.
+// It can be implemented by the type of an option, or by the elements of a slice.
+type Choicer interface {
+	Choices() []string
+}
+
 // Validator is a command or argument that wants to be validated.
 type Validator interface {
 	Validate() error
 }
 
-// Defaulter can set up the default arguments of a command
+// Defaulter can set up the default arguments of a command.
+// It is called at bind time on the command and on any option whose type implements it.
+// Precedence, from lowest to highest, is: the option type's Default(), the `default` tag,
+// an option group's Default(), the command's Default(), and finally user input.
 type Defaulter interface {
 	Default()
 }
 
+// Generated is implemented by commands (and globals) whose bindings were written by quackgen.
+// When present, quack registers flags and parses arguments of builtin types through the typed pointers of
+// QuackFields instead of unsafe casts, and calls QuackValidate instead of looking for validators itself.
+// Only those two steps are generated: binding still reflects over the struct for its tags, defaults, help and
+// option groups, and options missing from QuackFields, or of types quack doesn't register itself, are bound
+// as if nothing was generated, including their use of unsafe.
+type Generated interface {
+	// QuackFields returns a pointer to the field of each option, keyed by the option's name.
+	QuackFields() map[string]any
+	// QuackValidate validates the options and option groups of the command.
+	QuackValidate() error
+}
+
 // Parser is an argument that wants to parse itself.
 type Parser interface {
 	Parse(string) error
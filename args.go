@@ -1,15 +1,15 @@
 package quack
 
 import (
+	"encoding"
 	"fmt"
-	"log"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
-	"unsafe"
 
 	"github.com/iancoleman/strcase"
-	"github.com/spf13/pflag"
 )
 
 // filedNameToArg formats a filed name to it's name as cli arg.
@@ -17,223 +17,50 @@ func fieldNameToArg(f string) string {
 	return strcase.ToKebab(f)
 }
 
-func rawAddr[T any](v reflect.Value) *T {
-	return (*T)(unsafe.Pointer(v.UnsafeAddr()))
+// lookupEnv returns the value of the option's environment variable if it is set.
+func (o *option) lookupEnv() (string, bool) {
+	if o.Env == "" {
+		return "", false
+	}
+	return os.LookupEnv(o.Env)
 }
-func (o *option) setFlag(fs *pflag.FlagSet) {
-	if o.Ignore {
-		return
-	}
-	addr := o.Target.Addr().Interface()
-	hasShort := o.Short != ""
-	short := o.Short
-	strVal := o.Default
-	intVal, _ := strconv.Atoi(strVal)
-	floatVal, _ := strconv.ParseFloat(strVal, 64)
-	durationVal, _ := time.ParseDuration(strVal)
-	boolVal := strVal == "true"
-	argName := o.Name
-	help := o.Help
-	v := o.Target
 
-	// Handle slice types (automatically repeated)
-	if o.Target.Kind() == reflect.Slice {
-		elemType := o.Target.Type().Elem()
-		switch elemType.Kind() {
-		case reflect.String:
-			if hasShort {
-				fs.StringSliceVarP(rawAddr[[]string](v), argName, short, nil, help)
-			} else {
-				fs.StringSliceVar(rawAddr[[]string](v), argName, nil, help)
-			}
-			return
-		case reflect.Int:
-			if hasShort {
-				fs.IntSliceVarP(rawAddr[[]int](v), argName, short, nil, help)
-			} else {
-				fs.IntSliceVar(rawAddr[[]int](v), argName, nil, help)
-			}
-			return
-		case reflect.Int64:
-			if hasShort {
-				fs.Int64SliceVarP(rawAddr[[]int64](v), argName, short, nil, help)
-			} else {
-				fs.Int64SliceVar(rawAddr[[]int64](v), argName, nil, help)
-			}
-			return
-		case reflect.Int32:
-			if hasShort {
-				fs.Int32SliceVarP(rawAddr[[]int32](v), argName, short, nil, help)
-			} else {
-				fs.Int32SliceVar(rawAddr[[]int32](v), argName, nil, help)
-			}
-			return
-		case reflect.Uint:
-			if hasShort {
-				fs.UintSliceVarP(rawAddr[[]uint](v), argName, short, nil, help)
-			} else {
-				fs.UintSliceVar(rawAddr[[]uint](v), argName, nil, help)
-			}
-			return
-		case reflect.Float32:
-			if hasShort {
-				fs.Float32SliceVarP(rawAddr[[]float32](v), argName, short, nil, help)
-			} else {
-				fs.Float32SliceVar(rawAddr[[]float32](v), argName, nil, help)
-			}
-			return
-		case reflect.Float64:
-			if hasShort {
-				fs.Float64SliceVarP(rawAddr[[]float64](v), argName, short, nil, help)
-			} else {
-				fs.Float64SliceVar(rawAddr[[]float64](v), argName, nil, help)
-			}
-			return
-		case reflect.Bool:
-			if hasShort {
-				fs.BoolSliceVarP(rawAddr[[]bool](v), argName, short, nil, help)
-			} else {
-				fs.BoolSliceVar(rawAddr[[]bool](v), argName, nil, help)
-			}
-			return
-		default:
-			log.Panicf("Unable to handle slice type for repeated flag: %v", elemType.Kind())
-		}
+// usage is the help text for the option, including constraints and where else it can be set from.
+func (o *option) usage() string {
+	parts := []string{}
+	if o.Help != "" {
+		parts = append(parts, o.Help)
 	}
-
-	switch o.Target.Kind() {
-	case reflect.Bool:
-		if hasShort {
-			fs.BoolVarP(rawAddr[bool](v), argName, short, boolVal, help)
-		} else {
-			fs.BoolVar(rawAddr[bool](v), argName, boolVal, help)
-		}
-	case reflect.Int:
-		if hasShort {
-			fs.IntVarP(rawAddr[int](v), argName, short, intVal, help)
-		} else {
-			fs.IntVar(rawAddr[int](v), argName, intVal, help)
-		}
-		// handle a few types that are also int64
-	case reflect.Int64:
-		if addr, ok := addr.(*time.Duration); ok {
-			if hasShort {
-				fs.DurationVarP(addr, argName, short, durationVal, help)
-			} else {
-				fs.DurationVar(addr, argName, durationVal, help)
-			}
-		} else {
-			if hasShort {
-				fs.Int64VarP(rawAddr[int64](v), argName, short, int64(intVal), help)
-			} else {
-				fs.Int64Var(rawAddr[int64](v), argName, int64(intVal), help)
-			}
-		}
-	case reflect.Int32:
-		if hasShort {
-			fs.Int32VarP(rawAddr[int32](v), argName, short, int32(intVal), help)
-		} else {
-			fs.Int32Var(rawAddr[int32](v), argName, int32(intVal), help)
-		}
-	case reflect.Int16:
-		if hasShort {
-			fs.Int16VarP(rawAddr[int16](v), argName, short, int16(intVal), help)
-		} else {
-			fs.Int16Var(rawAddr[int16](v), argName, int16(intVal), help)
-		}
-	case reflect.Int8:
-		if hasShort {
-			fs.Int8VarP(rawAddr[int8](v), argName, short, int8(intVal), help)
-		} else {
-			fs.Int8Var(rawAddr[int8](v), argName, int8(intVal), help)
-		}
-	case reflect.Uint:
-		if hasShort {
-			fs.UintVarP(rawAddr[uint](v), argName, short, uint(intVal), help)
-		} else {
-			fs.UintVar(rawAddr[uint](v), argName, uint(intVal), help)
-		}
-	case reflect.Uint64:
-		if hasShort {
-			fs.Uint64VarP(
-				rawAddr[uint64](v),
-				argName,
-				short,
-				uint64(intVal),
-				help,
-			)
-		} else {
-			fs.Uint64Var(rawAddr[uint64](v), argName, uint64(intVal), help)
-		}
-	case reflect.Uint32:
-		if hasShort {
-			fs.Uint32VarP(
-				rawAddr[uint32](v),
-				argName,
-				short,
-				uint32(intVal),
-				help,
-			)
-		} else {
-			fs.Uint32Var(rawAddr[uint32](v), argName, uint32(intVal), help)
-		}
-	case reflect.Uint16:
-		if hasShort {
-			fs.Uint16VarP(
-				rawAddr[uint16](v),
-				argName,
-				short,
-				uint16(intVal),
-				help,
-			)
-		} else {
-			fs.Uint16Var(rawAddr[uint16](v), argName, uint16(intVal), help)
-		}
-	case reflect.Uint8:
-		if hasShort {
-			fs.Uint8VarP(rawAddr[uint8](v), argName, short, uint8(intVal), help)
-		} else {
-			fs.Uint8Var(rawAddr[uint8](v), argName, uint8(intVal), help)
-		}
-	case reflect.Float32:
-		if hasShort {
-			fs.Float32VarP(
-				rawAddr[float32](v),
-				argName,
-				short,
-				float32(floatVal),
-				help,
-			)
-		} else {
-			fs.Float32Var(rawAddr[float32](v), argName, float32(floatVal), help)
-		}
-	case reflect.Float64:
-		if hasShort {
-			fs.Float64VarP(
-				rawAddr[float64](v),
-				argName,
-				short,
-				float64(floatVal),
-				help,
-			)
-		} else {
-			fs.Float64Var(rawAddr[float64](v), argName, float64(floatVal), help)
-		}
-	case reflect.String:
-		if hasShort {
-			fs.StringVarP(rawAddr[string](v), argName, short, strVal, help)
-		} else {
-			fs.StringVar(rawAddr[string](v), argName, strVal, help)
-		}
-
-	default:
-		log.Panicf("Unable to handle type set flags for %v", o.Target)
+	parts = append(parts, o.notes...)
+	if len(o.Choices) > 0 {
+		parts = append(parts, fmt.Sprintf("(one of %s)", strings.Join(o.Choices, ", ")))
+	}
+	if o.Env != "" {
+		parts = append(parts, fmt.Sprintf("[$%s]", o.Env))
 	}
+	return strings.Join(parts, " ")
 }
 
 // parseValue parses a string value and assigns it to the target field
 func (o *option) parseValue(value string) error {
-	v := o.Target
+	if err := o.checkChoice(value); err != nil {
+		return err
+	}
+	if o.Counter && value == "+1" {
+		o.Target.SetInt(o.Target.Int() + 1)
+		return nil
+	}
+	if ok, err := parseTyped(o.ptr, value); ok {
+		return err
+	}
+	return o.parseElem(o.Target, value)
+}
+
+// parseScalar parses value into v, which must be addressable.
+func parseScalar(v reflect.Value, value string) error {
+	if isCustomElem(v.Type()) {
+		return parseCustom(v, value)
+	}
 	switch v.Kind() {
 	case reflect.String:
 		v.SetString(value)
@@ -246,20 +73,20 @@ func (o *option) parseValue(value string) error {
 			}
 			v.SetInt(int64(d))
 		} else {
-			i, err := strconv.ParseInt(value, 10, 64)
+			i, err := strconv.ParseInt(value, 10, v.Type().Bits())
 			if err != nil {
 				return fmt.Errorf("invalid integer: %w", err)
 			}
 			v.SetInt(i)
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		u, err := strconv.ParseUint(value, 10, 64)
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
 		if err != nil {
 			return fmt.Errorf("invalid unsigned integer: %w", err)
 		}
 		v.SetUint(u)
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(value, 64)
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
 		if err != nil {
 			return fmt.Errorf("invalid float: %w", err)
 		}
@@ -271,62 +98,259 @@ func (o *option) parseValue(value string) error {
 		}
 		v.SetBool(b)
 	default:
-		return fmt.Errorf("unsupported type for positional argument: %v", v.Kind())
+		return fmt.Errorf("unsupported type %v", v.Type())
 	}
 	return nil
 }
 
-// appendValue appends a value to a slice field (for repeated arguments)
-func (o *option) appendValue(value string) error {
-	v := o.Target
-	if v.Kind() != reflect.Slice {
-		return fmt.Errorf("repeated argument must be a slice, got %v", v.Kind())
+// setDefault assigns a default value to the target field.
+// Defaults for slices are comma separated.
+func (o *option) setDefault(value string) error {
+	return o.setSeparated(value, ",")
+}
+
+// setSeparated assigns value to the target field, replacing its current value.
+// Slices are split on sep.
+func (o *option) setSeparated(value, sep string) error {
+	if !isRepeated(o.Target) {
+		return o.parseValue(value)
 	}
+	o.Target.Set(reflect.Zero(o.Target.Type()))
+	for _, elem := range strings.Split(value, sep) {
+		if err := o.appendValue(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	elemType := v.Type().Elem()
-	elem := reflect.New(elemType).Elem()
+// setValue assigns a value passed on the command line. Repeated flags replace the default on first use,
+// then append, and builtin slice types also accept comma separated values.
+func (o *option) setValue(value string, first bool) error {
+	if !isRepeated(o.Target) {
+		return o.parseValue(value)
+	}
+	if first {
+		o.Target.Set(reflect.Zero(o.Target.Type()))
+	}
+	if isCustomElem(o.Target.Type().Elem()) {
+		return o.appendValue(value)
+	}
+	for _, elem := range strings.Split(value, ",") {
+		if err := o.appendValue(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Parse the value into the element
-	switch elemType.Kind() {
+// formatValue renders the current value of the target field so it can be used as a default.
+// Slices are comma separated, mirroring setDefault.
+func (o *option) formatValue() string {
+	v := o.Target
+	if isMap(v) {
+		return o.formatMap()
+	}
+	if isSlice(v) {
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = o.formatElem(v.Index(i))
+		}
+		return strings.Join(elems, ",")
+	}
+	return o.formatElem(v)
+}
+
+func formatScalar(v reflect.Value) string {
+	if isCustomElem(v.Type()) {
+		return formatCustom(v)
+	}
+	switch v.Kind() {
 	case reflect.String:
-		elem.SetString(value)
+		return v.String()
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if elemType == reflect.TypeOf(time.Duration(0)) {
-			d, err := time.ParseDuration(value)
-			if err != nil {
-				return fmt.Errorf("invalid duration: %w", err)
-			}
-			elem.SetInt(int64(d))
-		} else {
-			i, err := strconv.ParseInt(value, 10, 64)
-			if err != nil {
-				return fmt.Errorf("invalid integer: %w", err)
-			}
-			elem.SetInt(i)
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			return time.Duration(v.Int()).String()
 		}
+		return strconv.FormatInt(v.Int(), 10)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		u, err := strconv.ParseUint(value, 10, 64)
-		if err != nil {
-			return fmt.Errorf("invalid unsigned integer: %w", err)
-		}
-		elem.SetUint(u)
+		return strconv.FormatUint(v.Uint(), 10)
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return fmt.Errorf("invalid float: %w", err)
-		}
-		elem.SetFloat(f)
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
 	case reflect.Bool:
-		b, err := strconv.ParseBool(value)
-		if err != nil {
-			return fmt.Errorf("invalid boolean: %w", err)
-		}
-		elem.SetBool(b)
+		return strconv.FormatBool(v.Bool())
 	default:
-		return fmt.Errorf("unsupported element type for repeated argument: %v", elemType.Kind())
+		return fmt.Sprint(v.Interface())
 	}
+}
 
-	// Append the element to the slice
+// appendValue appends a value to a slice field (for repeated arguments)
+func (o *option) appendValue(value string) error {
+	if err := o.checkChoice(value); err != nil {
+		return err
+	}
+	if ok, err := appendTyped(o.ptr, value); ok {
+		return err
+	}
+	v := o.Target
+	if isMap(v) {
+		return o.putValue(value)
+	}
+	if !isSlice(v) {
+		return fmt.Errorf("repeated argument must be a slice or a map, got %v", v.Kind())
+	}
+
+	elem := reflect.New(v.Type().Elem()).Elem()
+	if err := o.parseElem(elem, value); err != nil {
+		return err
+	}
 	v.Set(reflect.Append(v, elem))
 	return nil
 }
+
+var (
+	parserType          = reflect.TypeOf((*Parser)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	typedValueType      = reflect.TypeOf((*typedValue)(nil)).Elem()
+)
+
+// typedValue is the pflag.Value interface, declared here so parsing doesn't depend on pflag.
+type typedValue interface {
+	String() string
+	Set(string) error
+	Type() string
+}
+
+// isCustom reports if values of type t know how to parse themselves,
+// via pflag.Value, Parser or encoding.TextUnmarshaler on a pointer to t.
+func isCustom(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(typedValueType) || pt.Implements(parserType) || pt.Implements(textUnmarshalerType)
+}
+
+// isSlice reports if the target is a repeated value.
+// Slice types that parse themselves (net.IP for example) are treated as a single value.
+func isSlice(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && !isCustom(v.Type())
+}
+
+// usesCustomValue reports if v is bound through a customValue rather than a builtin flag type.
+func usesCustomValue(v reflect.Value) bool {
+	return isCustom(v.Type()) || (isSlice(v) && isCustomElem(v.Type().Elem()))
+}
+
+// parseCustom parses value into v, which must be addressable and satisfy isCustom.
+// Pointers to such types are set to a newly parsed value.
+func parseCustom(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer && !isCustom(v.Type()) {
+		p := reflect.New(v.Type().Elem())
+		if err := parseCustom(p.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	switch p := v.Addr().Interface().(type) {
+	case typedValue:
+		return p.Set(value)
+	case Parser:
+		return p.Parse(value)
+	case encoding.TextUnmarshaler:
+		return p.UnmarshalText([]byte(value))
+	}
+	return fmt.Errorf("%v is unable to parse itself", v.Type())
+}
+
+// formatCustom renders v using String() or MarshalText() if available.
+func formatCustom(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer && !isCustom(v.Type()) {
+		if v.IsNil() {
+			return ""
+		}
+		return formatCustom(v.Elem())
+	}
+	var i any = v.Interface()
+	if v.CanAddr() {
+		i = v.Addr().Interface()
+	}
+	switch f := i.(type) {
+	case fmt.Stringer:
+		return f.String()
+	case encoding.TextMarshaler:
+		if b, err := f.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// customTypeName is the type name shown in help for a custom value.
+func customTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer && !isCustom(t) {
+		t = t.Elem()
+	}
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	if pv, ok := reflect.New(t).Interface().(typedValue); ok {
+		return pv.Type()
+	}
+	name := t.Name()
+	if name == "" {
+		name = t.String()
+	}
+	return strings.ToLower(name)
+}
+
+// customValue adapts a field whose type (or slice element type) parses itself
+// to both the pflag.Value and cli.Value interfaces.
+type customValue struct {
+	target  reflect.Value
+	changed bool
+}
+
+func newCustomValue(target reflect.Value) *customValue {
+	return &customValue{target: target}
+}
+
+func (c *customValue) Set(s string) error {
+	if !isSlice(c.target) {
+		return parseCustom(c.target, s)
+	}
+	// the first value replaces the default, the rest are appended
+	if !c.changed {
+		c.target.Set(reflect.Zero(c.target.Type()))
+	}
+	elem := reflect.New(c.target.Type().Elem()).Elem()
+	if err := parseCustom(elem, s); err != nil {
+		return err
+	}
+	c.target.Set(reflect.Append(c.target, elem))
+	c.changed = true
+	return nil
+}
+
+func (c *customValue) String() string {
+	if !c.target.IsValid() {
+		return ""
+	}
+	if !isSlice(c.target) {
+		return formatCustom(c.target)
+	}
+	elems := make([]string, c.target.Len())
+	for i := range elems {
+		elems[i] = formatCustom(c.target.Index(i))
+	}
+	return "[" + strings.Join(elems, ",") + "]"
+}
+
+func (c *customValue) Type() string {
+	if isSlice(c.target) {
+		return customTypeName(c.target.Type().Elem()) + "Slice"
+	}
+	return customTypeName(c.target.Type())
+}
+
+func (c *customValue) Get() any {
+	return c.target.Interface()
+}
@@ -0,0 +1,269 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"github.com/spf13/pflag"
+)
+
+func rawAddr[T any](v reflect.Value) *T {
+	return (*T)(unsafe.Pointer(v.UnsafeAddr()))
+}
+
+// setFlag registers the option on fs, hidden or deprecated as its tags ask.
+// Aliases are resolved by the normalize func of the flag set, see setupCobra.
+func (o *option) setFlag(fs *pflag.FlagSet) {
+	if o.Ignore {
+		return
+	}
+	switch {
+	case o.Counter:
+		// pflag's own counter starts from zero rather than the default
+		fs.VarPF(&flagValue{o: o}, o.Name, o.Short, o.usage()).NoOptDefVal = "+1"
+	case o.parsedByOption():
+		// pflag's own flags would skip checking the choices, layout and schemes
+		fs.VarP(&flagValue{o: o}, o.Name, o.Short, o.usage())
+	case o.ptr == nil || !o.setTypedFlag(fs):
+		o.addFlag(fs)
+	}
+	if o.negatable() {
+		// help shows it as --[no-]name, see fmtUsage
+		negation := fs.VarPF(&flagValue{o: o, negate: true}, negationPrefix+o.Name, "", "turn off --"+o.Name)
+		negation.NoOptDefVal = "true"
+		negation.Hidden = true
+	}
+	if o.Hidden {
+		fs.MarkHidden(o.Name)
+	}
+	if o.Deprecated != "" {
+		fs.MarkDeprecated(o.Name, o.Deprecated)
+	}
+}
+
+// addFlag registers the option on fs through reflection.
+func (o *option) addFlag(fs *pflag.FlagSet) {
+	addr := o.Target.Addr().Interface()
+	hasShort := o.Short != ""
+	short := o.Short
+	strVal := o.Default
+	intVal, _ := strconv.Atoi(strVal)
+	floatVal, _ := strconv.ParseFloat(strVal, 64)
+	durationVal, _ := time.ParseDuration(strVal)
+	boolVal := strVal == "true"
+	argName := o.Name
+	help := o.usage()
+	v := o.Target
+
+	// Types that parse themselves, or slices of them
+	if usesCustomValue(v) {
+		fs.VarP(newCustomValue(v), argName, short, help)
+		return
+	}
+
+	// Maps take repeated key=value entries
+	if isMap(v) {
+		o.setMapFlag(fs)
+		return
+	}
+
+	// Handle slice types (automatically repeated)
+	if isSlice(o.Target) {
+		elemType := o.Target.Type().Elem()
+		switch elemType.Kind() {
+		case reflect.String:
+			if hasShort {
+				fs.StringSliceVarP(rawAddr[[]string](v), argName, short, *rawAddr[[]string](v), help)
+			} else {
+				fs.StringSliceVar(rawAddr[[]string](v), argName, *rawAddr[[]string](v), help)
+			}
+			return
+		case reflect.Int:
+			if hasShort {
+				fs.IntSliceVarP(rawAddr[[]int](v), argName, short, *rawAddr[[]int](v), help)
+			} else {
+				fs.IntSliceVar(rawAddr[[]int](v), argName, *rawAddr[[]int](v), help)
+			}
+			return
+		case reflect.Int64:
+			if hasShort {
+				fs.Int64SliceVarP(rawAddr[[]int64](v), argName, short, *rawAddr[[]int64](v), help)
+			} else {
+				fs.Int64SliceVar(rawAddr[[]int64](v), argName, *rawAddr[[]int64](v), help)
+			}
+			return
+		case reflect.Int32:
+			if hasShort {
+				fs.Int32SliceVarP(rawAddr[[]int32](v), argName, short, *rawAddr[[]int32](v), help)
+			} else {
+				fs.Int32SliceVar(rawAddr[[]int32](v), argName, *rawAddr[[]int32](v), help)
+			}
+			return
+		case reflect.Uint:
+			if hasShort {
+				fs.UintSliceVarP(rawAddr[[]uint](v), argName, short, *rawAddr[[]uint](v), help)
+			} else {
+				fs.UintSliceVar(rawAddr[[]uint](v), argName, *rawAddr[[]uint](v), help)
+			}
+			return
+		case reflect.Float32:
+			if hasShort {
+				fs.Float32SliceVarP(rawAddr[[]float32](v), argName, short, *rawAddr[[]float32](v), help)
+			} else {
+				fs.Float32SliceVar(rawAddr[[]float32](v), argName, *rawAddr[[]float32](v), help)
+			}
+			return
+		case reflect.Float64:
+			if hasShort {
+				fs.Float64SliceVarP(rawAddr[[]float64](v), argName, short, *rawAddr[[]float64](v), help)
+			} else {
+				fs.Float64SliceVar(rawAddr[[]float64](v), argName, *rawAddr[[]float64](v), help)
+			}
+			return
+		case reflect.Bool:
+			if hasShort {
+				fs.BoolSliceVarP(rawAddr[[]bool](v), argName, short, *rawAddr[[]bool](v), help)
+			} else {
+				fs.BoolSliceVar(rawAddr[[]bool](v), argName, *rawAddr[[]bool](v), help)
+			}
+			return
+		}
+		// pflag has no flag for slices of other types, so they parse like the other backends
+		fs.VarP(&flagValue{o: o}, argName, short, help)
+		return
+	}
+
+	switch o.Target.Kind() {
+	case reflect.Bool:
+		if hasShort {
+			fs.BoolVarP(rawAddr[bool](v), argName, short, boolVal, help)
+		} else {
+			fs.BoolVar(rawAddr[bool](v), argName, boolVal, help)
+		}
+	case reflect.Int:
+		if hasShort {
+			fs.IntVarP(rawAddr[int](v), argName, short, intVal, help)
+		} else {
+			fs.IntVar(rawAddr[int](v), argName, intVal, help)
+		}
+		// handle a few types that are also int64
+	case reflect.Int64:
+		if addr, ok := addr.(*time.Duration); ok {
+			if hasShort {
+				fs.DurationVarP(addr, argName, short, durationVal, help)
+			} else {
+				fs.DurationVar(addr, argName, durationVal, help)
+			}
+		} else {
+			if hasShort {
+				fs.Int64VarP(rawAddr[int64](v), argName, short, int64(intVal), help)
+			} else {
+				fs.Int64Var(rawAddr[int64](v), argName, int64(intVal), help)
+			}
+		}
+	case reflect.Int32:
+		if hasShort {
+			fs.Int32VarP(rawAddr[int32](v), argName, short, int32(intVal), help)
+		} else {
+			fs.Int32Var(rawAddr[int32](v), argName, int32(intVal), help)
+		}
+	case reflect.Int16:
+		if hasShort {
+			fs.Int16VarP(rawAddr[int16](v), argName, short, int16(intVal), help)
+		} else {
+			fs.Int16Var(rawAddr[int16](v), argName, int16(intVal), help)
+		}
+	case reflect.Int8:
+		if hasShort {
+			fs.Int8VarP(rawAddr[int8](v), argName, short, int8(intVal), help)
+		} else {
+			fs.Int8Var(rawAddr[int8](v), argName, int8(intVal), help)
+		}
+	case reflect.Uint:
+		if hasShort {
+			fs.UintVarP(rawAddr[uint](v), argName, short, uint(intVal), help)
+		} else {
+			fs.UintVar(rawAddr[uint](v), argName, uint(intVal), help)
+		}
+	case reflect.Uint64:
+		if hasShort {
+			fs.Uint64VarP(
+				rawAddr[uint64](v),
+				argName,
+				short,
+				uint64(intVal),
+				help,
+			)
+		} else {
+			fs.Uint64Var(rawAddr[uint64](v), argName, uint64(intVal), help)
+		}
+	case reflect.Uint32:
+		if hasShort {
+			fs.Uint32VarP(
+				rawAddr[uint32](v),
+				argName,
+				short,
+				uint32(intVal),
+				help,
+			)
+		} else {
+			fs.Uint32Var(rawAddr[uint32](v), argName, uint32(intVal), help)
+		}
+	case reflect.Uint16:
+		if hasShort {
+			fs.Uint16VarP(
+				rawAddr[uint16](v),
+				argName,
+				short,
+				uint16(intVal),
+				help,
+			)
+		} else {
+			fs.Uint16Var(rawAddr[uint16](v), argName, uint16(intVal), help)
+		}
+	case reflect.Uint8:
+		if hasShort {
+			fs.Uint8VarP(rawAddr[uint8](v), argName, short, uint8(intVal), help)
+		} else {
+			fs.Uint8Var(rawAddr[uint8](v), argName, uint8(intVal), help)
+		}
+	case reflect.Float32:
+		if hasShort {
+			fs.Float32VarP(
+				rawAddr[float32](v),
+				argName,
+				short,
+				float32(floatVal),
+				help,
+			)
+		} else {
+			fs.Float32Var(rawAddr[float32](v), argName, float32(floatVal), help)
+		}
+	case reflect.Float64:
+		if hasShort {
+			fs.Float64VarP(
+				rawAddr[float64](v),
+				argName,
+				short,
+				float64(floatVal),
+				help,
+			)
+		} else {
+			fs.Float64Var(rawAddr[float64](v), argName, float64(floatVal), help)
+		}
+	case reflect.String:
+		if hasShort {
+			fs.StringVarP(rawAddr[string](v), argName, short, strVal, help)
+		} else {
+			fs.StringVar(rawAddr[string](v), argName, strVal, help)
+		}
+
+	default:
+		// unsupported types are reported by validate before any flags are registered
+		fs.VarP(&flagValue{o: o}, argName, short, help)
+	}
+}
@@ -1,38 +1,85 @@
 package quack
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"maps"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"unsafe"
 
-	"github.com/spf13/cobra"
+	"github.com/iancoleman/strcase"
 )
 
 var ()
 
 const (
-	helpTag     = "help"
-	defaultTag  = "default"
-	shortTag    = "short"
-	longTag     = "long"
-	ignoreTag   = "ignore"
-	argTag      = "arg"
-	repeatedTag = "repeated"
+	helpTag       = "help"
+	defaultTag    = "default"
+	shortTag      = "short"
+	longTag       = "long"
+	ignoreTag     = "ignore"
+	prefixTag     = "prefix"
+	argTag        = "arg"
+	repeatedTag   = "repeated"
+	envTag        = "env"
+	requiredTag   = "required"
+	xorTag        = "xor"
+	andTag        = "and"
+	requiresTag   = "requires"
+	categoryTag   = "category"
+	aliasTag      = "alias"
+	hiddenTag     = "hidden"
+	deprecatedTag = "deprecated"
+	enumTag       = "enum"
+	counterTag    = "counter"
+	noNegateTag   = "nonegate"
+	sepTag        = "sep"
+	duplicatesTag = "duplicates"
+	layoutTag     = "layout"
+	schemeTag     = "scheme"
+	minTag        = "min"
+	baseTag       = "base"
+	overwriteTag  = "overwrite"
 )
 
 type option struct {
-	Name     string
-	Target   reflect.Value
-	Help     string
-	Default  string
-	Short    string
-	Long     string
-	Ignore   bool
-	Arg      int  // 0 means not a positional arg, >0 means positional argument at that index
-	Repeated bool
+	Name       string
+	Target     reflect.Value
+	Help       string
+	Default    string
+	Short      string
+	Long       string
+	Ignore     bool
+	Arg        int // 0 means not a positional arg, >0 means positional argument at that index
+	Repeated   bool
+	Env        string // environment variable the option can be read from
+	Required   bool
+	Xor        []string      // groups of mutually exclusive options
+	And        []string      // groups of options that must be used together
+	Requires   []string      // names of options that must be set along with this one
+	Aliases    []string      // other long names the option can be passed as
+	Hidden     bool          // left out of help
+	Deprecated string        // warning printed when the option is used, which also hides it
+	Choices    []string      // values the option is limited to, see Choicer
+	Counter    bool          // an int counting how many times the flag is passed
+	NoNegate   bool          // a bool without a --no-<name> flag
+	Sep        string        // separator between the keys and values of a map
+	Duplicates string        // what to do with a key given twice to a map: last, first or error
+	Layout     string        // layout of a time.Time, as passed to time.Parse
+	Schemes    []string      // schemes a URL is limited to
+	Min        int           // number of files a Glob must match at least
+	Base       string        // directory a PathWithin must stay in
+	Overwrite  string        // name of the bool option that lets a NewFilePath exist
+	notes      []string      // extra help, such as constraints
+	ptr        any           // typed pointer to the field from generated code, see Generated
+	pointer    reflect.Value // a pointer field, left nil until the option is set; Target holds its value meanwhile
+
+	provided bool // set by the user, env or config during the current run
 }
 
 func (o *option) fmtBuffer(w io.Writer) {
@@ -51,7 +98,7 @@ func (o *option) fmtBuffer(w io.Writer) {
 	)
 }
 
-func optionFromField(field reflect.StructField) option {
+func optionFromField(field reflect.StructField) (option, error) {
 	opt := option{
 		Name: fieldNameToArg(field.Name),
 	}
@@ -60,19 +107,44 @@ func optionFromField(field reflect.StructField) option {
 	opt.Short = tags.Get(shortTag)
 	opt.Long = tags.Get(longTag)
 	opt.Default = tags.Get(defaultTag)
+	opt.Env = tags.Get(envTag)
 	_, opt.Ignore = tags.Lookup(ignoreTag)
 	_, opt.Repeated = tags.Lookup(repeatedTag)
+	_, opt.Required = tags.Lookup(requiredTag)
+	opt.Xor = splitTag(tags.Get(xorTag))
+	opt.And = splitTag(tags.Get(andTag))
+	opt.Requires = splitTag(tags.Get(requiresTag))
+	opt.Aliases = splitTag(tags.Get(aliasTag))
+	_, opt.Hidden = tags.Lookup(hiddenTag)
+	opt.Deprecated = tags.Get(deprecatedTag)
+	opt.Choices = splitTag(tags.Get(enumTag))
+	opt.Layout = tags.Get(layoutTag)
+	opt.Schemes = splitTag(tags.Get(schemeTag))
+	opt.Base = tags.Get(baseTag)
+	opt.Overwrite = tags.Get(overwriteTag)
+	_, opt.Counter = tags.Lookup(counterTag)
+	_, opt.NoNegate = tags.Lookup(noNegateTag)
+	opt.Sep = tags.Get(sepTag)
+	opt.Duplicates = tags.Get(duplicatesTag)
+
+	if minStr := tags.Get(minTag); minStr != "" {
+		n, err := strconv.Atoi(minStr)
+		if err != nil || n < 1 {
+			return opt, fmt.Errorf("invalid min value '%s' for field %s: must be a positive integer", minStr, field.Name)
+		}
+		opt.Min = n
+	}
 
 	// Parse arg tag
 	if argStr := tags.Get(argTag); argStr != "" {
 		arg, err := strconv.Atoi(argStr)
 		if err != nil || arg < 1 {
-			panic(fmt.Sprintf("invalid arg value '%s' for field %s: must be a positive integer", argStr, field.Name))
+			return opt, fmt.Errorf("invalid arg value '%s' for field %s: must be a positive integer", argStr, field.Name)
 		}
 		opt.Arg = arg
 	}
 
-	return opt
+	return opt, nil
 }
 
 // node is a tree structure of commands that binds a structure to an abstract tree for cli applications.
@@ -80,66 +152,160 @@ type node struct {
 	name              string
 	long              string
 	short             string
-	run               func(*cobra.Command, []string)
+	run               func(context.Context, []string) error
 	options           []option
 	positionalOptions []option
 	subcommands       []*node
-	target            any // Store the original target for framework-specific handling
+	groups            []reflect.Value // structs of options, flattened into options
+	globals           *node           // options shared with every descendant, see Globaler
+	injections        []injection     // fields that receive the globals of an ancestor
+	problems          []error         // mistakes in struct tags, reported together by validate
+	fields            map[string]any  // typed pointers to the options, when the target is Generated
+	pending           bool            // the options haven't been bound yet, see WithLazy
+	isGroup           bool            // groups only show help when run, and don't run hooks
+	category          string          // heading the command is listed under in its parent's help
+	aliases           []string        // other names the command can be run as, see Aliaser
+	hidden            bool            // left out of help, see Hider
+	deprecated        string          // warning printed when the command runs, see Deprecator
+	target            any             // Store the original target for framework-specific handling
+	parent            *node
+	cfg               *bindConfig
 }
 
-func (c *node) toCobra() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   c.name,
-		Long:  c.long,
-		Short: c.short,
+// lineage returns all nodes from the root to c.
+func (c *node) lineage() []*node {
+	if c.parent == nil {
+		return []*node{c}
 	}
-	flags := cmd.Flags()
-	for _, o := range c.options {
-		o.setFlag(flags)
+	return append(c.parent.lineage(), c)
+}
+
+// path returns the names of all commands from the root to c.
+func (c *node) path() []string {
+	var names []string
+	for _, n := range c.lineage() {
+		names = append(names, n.name)
 	}
+	return names
+}
 
-	for _, s := range c.subcommands {
-		cmd.AddCommand(s.toCobra())
+// envName derives the environment variable for o when automatic env binding is enabled.
+func (c *node) envName(o *option) string {
+	path := c.path()
+	if c.cfg.envPrefix != "" {
+		path[0] = c.cfg.envPrefix
+	}
+	parts := make([]string, 0, len(path)+1)
+	for _, p := range path {
+		parts = append(parts, strcase.ToScreamingSnake(p))
 	}
+	parts = append(parts, strcase.ToScreamingSnake(o.Name))
+	return strings.Join(parts, "_")
+}
 
-	// Wrap the run function to handle positional arguments and validation
-	if c.run != nil {
-		originalRun := c.run
-		cmd.RunE = func(cobraCmd *cobra.Command, args []string) error {
-			// Parse positional arguments
-			if err := c.parsePositionalArgs(args); err != nil {
-				return err
-			}
-			// Validate options if command doesn't implement Validator
-			if err := c.validateOptions(); err != nil {
-				return err
-			}
-			originalRun(cobraCmd, args)
-			return nil
+// prepare fills in everything the framework didn't parse and validates the result.
+// isSet reports if the user passed the named flag on the command line.
+// The returned context carries the globals of every group above c.
+func (c *node) prepare(ctx context.Context, args []string, isSet func(name string) bool) (context.Context, error) {
+	lineage := c.lineage()
+
+	// The globals of every group from the root down, then the command's own options
+	var optionSets [][]option
+	for _, n := range lineage {
+		if n.globals != nil {
+			optionSets = append(optionSets, n.globals.options)
+		}
+	}
+	optionSets = append(optionSets, c.options)
+
+	// Environment variables, then config files, for any flags that weren't passed
+	for _, options := range optionSets {
+		for i := range options {
+			options[i].provided = isSet(options[i].Name)
+		}
+		if err := c.applyEnv(options); err != nil {
+			return ctx, err
+		}
+	}
+	if err := c.applyConfig(); err != nil {
+		return ctx, err
+	}
+	if err := checkConstraints(c.flagOptions()); err != nil {
+		return ctx, err
+	}
+
+	for _, n := range lineage {
+		if n.globals == nil {
+			continue
+		}
+		if err := n.globals.validateOptions(); err != nil {
+			return ctx, err
 		}
+		ctx = context.WithValue(ctx, globalsKey{n.globals.targetValue().Type()}, n.globals.target)
+	}
+	c.injectGlobals()
+	// Parse positional arguments
+	if err := c.parsePositionalArgs(args); err != nil {
+		return ctx, err
+	}
+	if err := c.checkNewFiles(); err != nil {
+		return ctx, err
+	}
+	for _, options := range append(optionSets, c.positionalOptions) {
+		setPointers(options)
 	}
+	// Validate options if command doesn't implement Validator
+	return ctx, c.validateOptions()
+}
 
-	return cmd
+// applyEnv sets named options from their environment variables, unless they were already provided.
+func (c *node) applyEnv(options []option) error {
+	for i := range options {
+		o := &options[i]
+		if o.Ignore || o.provided {
+			continue
+		}
+		if value, ok := o.lookupEnv(); ok {
+			if err := o.setSeparated(value, c.cfg.envSeparator); err != nil {
+				return fmt.Errorf("failed to parse %s from $%s: %w", o.Name, o.Env, err)
+			}
+			o.provided = true
+		}
+	}
+	return nil
 }
 
 // parsePositionalArgs parses positional arguments and assigns them to the appropriate fields
 func (c *node) parsePositionalArgs(args []string) error {
 	argIndex := 0
-	for _, opt := range c.positionalOptions {
+	for i := range c.positionalOptions {
+		opt := &c.positionalOptions[i]
+		opt.provided = argIndex < len(args)
 		if argIndex >= len(args) {
 			// Not enough arguments provided
+			if value, ok := opt.lookupEnv(); ok {
+				if err := opt.setSeparated(value, c.cfg.envSeparator); err != nil {
+					return fmt.Errorf("failed to parse %s from $%s: %w", opt.Name, opt.Env, err)
+				}
+				opt.provided = true
+				continue
+			}
+			if opt.pointer.IsValid() {
+				// optional, the field stays nil
+				continue
+			}
 			if opt.Default == "" {
 				return fmt.Errorf("missing required positional argument: %s", opt.Name)
 			}
 			// Use default value
-			if err := opt.parseValue(opt.Default); err != nil {
+			if err := opt.setDefault(opt.Default); err != nil {
 				return fmt.Errorf("failed to parse default value for %s: %w", opt.Name, err)
 			}
 			continue
 		}
 
-		// Check if this is a slice type (repeated argument)
-		if opt.Target.Kind() == reflect.Slice {
+		// Check if this is a slice or map type (repeated argument)
+		if isRepeated(opt.Target) {
 			// Consume all remaining arguments
 			for argIndex < len(args) {
 				if err := opt.appendValue(args[argIndex]); err != nil {
@@ -158,6 +324,19 @@ func (c *node) parsePositionalArgs(args []string) error {
 	return nil
 }
 
+// setPointers points the fields of pointer options that were set to their values.
+// Options that weren't keep their field as it was, nil unless it was set beforehand.
+func setPointers(options []option) {
+	for _, o := range options {
+		if o.Ignore || !o.pointer.IsValid() || !o.provided {
+			continue
+		}
+		p := reflect.New(o.Target.Type())
+		p.Elem().Set(o.Target)
+		o.pointer.Set(p)
+	}
+}
+
 // validateOptions validates individual options that implement the Validator interface
 // if the command itself doesn't implement Validator
 func (c *node) validateOptions() error {
@@ -166,6 +345,9 @@ func (c *node) validateOptions() error {
 		// Command implements Validator, so we don't validate individual options
 		return nil
 	}
+	if g, ok := c.generated(c.target); ok {
+		return g.QuackValidate()
+	}
 
 	// Validate all options (both named and positional)
 	allOptions := append(c.options, c.positionalOptions...)
@@ -175,8 +357,15 @@ func (c *node) validateOptions() error {
 		}
 
 		// Check if the option's value implements Validator
-		if opt.Target.CanInterface() {
-			if validator, ok := opt.Target.Interface().(Validator); ok {
+		value := opt.Target
+		if opt.pointer.IsValid() {
+			// pointer options are only validated once set
+			if value = opt.pointer; value.IsNil() {
+				continue
+			}
+		}
+		if value.CanInterface() {
+			if validator, ok := value.Interface().(Validator); ok {
 				if err := validator.Validate(); err != nil {
 					return fmt.Errorf("validation failed for option %s: %w", opt.Name, err)
 				}
@@ -184,6 +373,15 @@ func (c *node) validateOptions() error {
 		}
 	}
 
+	// Validate option groups
+	for _, g := range c.groups {
+		if validator, ok := g.Addr().Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				return fmt.Errorf("validation failed for %s: %w", g.Type().Name(), err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -209,10 +407,6 @@ func (m *mapWrapper) SubCommands() Map {
 	return m.m
 }
 
-func (m *mapWrapper) Run(cmd *cobra.Command) {
-	cmd.Help()
-}
-
 func (c *node) fromStruct(name string, target any) error {
 	if m, ok := target.(Map); ok {
 		target = &mapWrapper{m}
@@ -229,6 +423,9 @@ func (c *node) fromStruct(name string, target any) error {
 	}
 	c.name = name
 	c.target = target // Store the target for framework-specific handling
+	if c.category == "" {
+		c.category = categoryOf(t)
+	}
 
 	if helper, ok := target.(Helper); ok {
 		c.long = helper.Help()
@@ -237,10 +434,19 @@ func (c *node) fromStruct(name string, target any) error {
 	if sh, ok := target.(ShortHelper); ok {
 		c.short = sh.ShortHelp()
 	}
+	if a, ok := target.(Aliaser); ok {
+		c.aliases = a.Aliases()
+	}
+	if h, ok := target.(Hider); ok {
+		c.hidden = h.Hidden()
+	}
+	if d, ok := target.(Deprecator); ok {
+		c.deprecated = d.Deprecated()
+	}
 
-	// Check if target has a Run method that might be UrfaveCommand
+	// Check if target has a Run method that might be CobraCommand or UrfaveCommand
 	// We check this using reflection to avoid import dependencies
-	hasUrfaveRun := false
+	hasCobraRun, hasUrfaveRun := false, false
 	targetValue := reflect.ValueOf(target)
 	runMethod := targetValue.MethodByName("Run")
 	if runMethod.IsValid() {
@@ -255,59 +461,277 @@ func (c *node) fromStruct(name string, target any) error {
 				hasUrfaveRun = true
 			}
 		}
+		// Check if it matches the CobraCommand signature: Run(cmd *cobra.Command, args []string)
+		if methodType.NumIn() == 2 && methodType.NumOut() == 0 && methodType.In(1) == reflect.TypeOf([]string(nil)) {
+			cmdType := methodType.In(0)
+			hasCobraRun = cmdType.Kind() == reflect.Pointer &&
+				cmdType.Elem().PkgPath() == "github.com/spf13/cobra" && cmdType.Elem().Name() == "Command"
+		}
+	}
+
+	// with WithLazy, only the root's options are bound up front.
+	// Otherwise they are bound before the subcommands, which can receive the globals.
+	if c.cfg.lazy && c.parent != nil {
+		c.pending = true
+	} else if err := c.bindOptions(); err != nil {
+		return err
 	}
 
 	switch target := target.(type) {
+	case CommandE:
+		c.run = func(ctx context.Context, s []string) error {
+			return target.RunE(ctx, s)
+		}
+	case ContextCommand:
+		c.run = func(ctx context.Context, _ []string) error {
+			return target.RunContext(ctx)
+		}
 	case Command:
-		c.run = func(c *cobra.Command, s []string) {
+		c.run = func(_ context.Context, s []string) error {
 			target.Run(s)
+			return nil
 		}
 	case SimpleCommand:
-		c.run = func(*cobra.Command, []string) {
+		c.run = func(context.Context, []string) error {
 			target.Run()
+			return nil
 		}
-	case CobraCommand:
-		c.run = target.Run
 	case UrfaveCommand:
-		// UrfaveCommand is handled differently in urfave binding
-		// We set a placeholder run function here
-		c.run = func(*cobra.Command, []string) {
-			// This will be overridden in toUrfaveApp/toUrfaveCommand
-		}
-	case Group:
-		c.run = func(c *cobra.Command, s []string) {
-			c.Help()
-		}
-		for name, s := range target.SubCommands() {
-			cn := new(node)
-			if err := cn.fromStruct(name, s); err != nil {
+		// overridden in toUrfaveCommand
+		c.run = c.frameworkOnly("urfave/cli")
+	case Group, OrderedGroup:
+		c.isGroup = true
+		// each backend shows the group's help after running this
+		c.run = func(context.Context, []string) error {
+			return nil
+		}
+		for _, e := range subCommands(target) {
+			if c.subcommand(e.Name) != nil {
+				c.problem(fmt.Errorf("duplicate command %s", e.Name))
+				continue
+			}
+			cn := &node{parent: c, cfg: c.cfg, category: e.Category}
+			if err := cn.fromStruct(e.Name, e.Command); err != nil {
 				return err
 			}
+			for _, alias := range cn.aliases {
+				if alias == e.Name || c.subcommand(alias) != nil {
+					c.problem(fmt.Errorf("duplicate command %s", alias))
+				}
+			}
 			c.subcommands = append(c.subcommands, cn)
 		}
 	default:
-		// Check if it implements UrfaveCommand pattern via reflection
-		if hasUrfaveRun {
-			c.run = func(*cobra.Command, []string) {
-				// This will be overridden in toUrfaveApp/toUrfaveCommand
-			}
+		// Check if it implements CobraCommand or UrfaveCommand pattern via reflection
+		if hasCobraRun {
+			// overridden in toCobra
+			c.run = c.frameworkOnly("cobra")
+		} else if hasUrfaveRun {
+			c.run = c.frameworkOnly("urfave/cli")
 		} else {
-			return fmt.Errorf("%w. must impliment quack.(Command|SimpleCommand|Group|SubCommander)", ErrNotACommand)
+			return fmt.Errorf("%w. must impliment quack.(Command|CommandE|SimpleCommand|ContextCommand|Group|SubCommander)", ErrNotACommand)
+		}
+	}
+
+	return nil
+}
+
+// subCommands lists the subcommands of a group, in the given order for an OrderedGroup and by name otherwise.
+func subCommands(group any) []Entry {
+	if g, ok := group.(OrderedGroup); ok {
+		return g.OrderedSubCommands()
+	}
+	m := group.(Group).SubCommands()
+	entries := make([]Entry, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		entries = append(entries, Entry{Name: name, Command: m[name]})
+	}
+	return entries
+}
+
+// categoryOf reads the `category` tag of the blank field a command declares its category with:
+//
+//	_ struct{} `category:"Management"`
+func categoryOf(t reflect.Type) string {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Name == "_" {
+			if category, ok := f.Tag.Lookup(categoryTag); ok {
+				return category
+			}
+		}
+	}
+	return ""
+}
+
+// bindOptions reflects over the fields of the command's struct to find its options and globals.
+func (c *node) bindOptions() error {
+	if g, ok := c.target.(Globaler); ok {
+		if err := c.bindGlobals(g.Globals()); err != nil {
+			return err
+		}
+	}
+
+	if g, ok := c.generated(c.target); ok {
+		c.fields = g.QuackFields()
+	}
+	c.addOptions(c.targetValue(), "")
+	c.bindConstraints()
+
+	// Sort positional args by their arg position
+	sort.Slice(c.positionalOptions, func(i, j int) bool {
+		return c.positionalOptions[i].Arg < c.positionalOptions[j].Arg
+	})
+
+	c.applyDefaults()
+	return nil
+}
+
+// load binds the options of a command that was left pending by WithLazy, once it is about to run,
+// and reports the mistakes validate would have found at bind time.
+func (c *node) load() error {
+	if !c.pending {
+		return nil
+	}
+	c.pending = false
+	if err := c.bindOptions(); err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+// generated returns the bindings quackgen wrote for target, unless WithReflection turned them off.
+func (c *node) generated(target any) (Generated, bool) {
+	g, ok := target.(Generated)
+	return g, ok && !c.cfg.reflectOnly
+}
+
+// frameworkOnly is the run function of commands that take a framework's command as an argument,
+// and can only be run by that framework's backend.
+func (c *node) frameworkOnly(framework string) func(context.Context, []string) error {
+	return func(context.Context, []string) error {
+		return fmt.Errorf("%w: %s can only be run with %s", ErrNotACommand, strings.Join(c.path(), " "), framework)
+	}
+}
+
+// applyDefaults resolves the default value of every option before any flags are registered.
+// Defaults are applied in order of increasing precedence:
+//
+//  1. Default() on the field's type
+//  2. the `default` struct tag
+//  3. Default() on option groups (embedded or named struct fields)
+//  4. Default() on the command itself
+//
+// The resulting field values become the defaults shown in help, and are overridden by user input.
+// Defaults that don't parse are reported by validate.
+func (c *node) applyDefaults() {
+	all := make([]*option, 0, len(c.options)+len(c.positionalOptions))
+	for i := range c.options {
+		all = append(all, &c.options[i])
+	}
+	for i := range c.positionalOptions {
+		all = append(all, &c.positionalOptions[i])
+	}
+
+	for _, o := range all {
+		if o.Ignore || !o.Target.CanSet() {
+			continue
+		}
+		if d, ok := o.Target.Addr().Interface().(Defaulter); ok {
+			d.Default()
+		}
+		if o.Default != "" {
+			if err := o.setDefault(o.Default); err != nil {
+				c.problem(fmt.Errorf("invalid default value %q for %s: %w", o.Default, o.Name, err))
+			}
+		}
+	}
+
+	for _, g := range c.groups {
+		if d, ok := g.Addr().Interface().(Defaulter); ok {
+			d.Default()
+		}
+	}
+
+	if d, ok := c.target.(Defaulter); ok {
+		d.Default()
+	}
+
+	for _, o := range all {
+		if o.Ignore || !o.Target.CanSet() {
+			continue
+		}
+		if o.Default != "" || !o.Target.IsZero() {
+			o.Default = o.formatValue()
 		}
 	}
+}
 
-	v.FieldByNameFunc(func(name string) bool {
-		f := v.FieldByName(name)
-		sf, ok := t.FieldByName(name)
-		if !ok {
-			panic("wtf")
+// addOptions walks the fields of the struct v. Embedded structs are flattened into the command's options,
+// and named struct fields become option groups whose names are prefixed with the field name (or `prefix` tag).
+func (c *node) addOptions(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := v.Field(i)
+		if _, ignore := sf.Tag.Lookup(ignoreTag); ignore && (sf.Anonymous || isGroup(sf)) {
+			continue
+		}
+		if g := c.globalsOfType(sf.Type); g != nil {
+			c.injections = append(c.injections, injection{field: f, globals: g})
+			continue
+		}
+		if sf.Anonymous && isGroup(sf) {
+			// fields promoted from unexported embedded types are still reachable
+			if !sf.IsExported() {
+				f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+			}
+			c.addOptions(f, prefix)
+			c.groups = append(c.groups, f)
+			continue
 		}
-		// embedded structs will be iterated over later
 		if sf.Anonymous || !sf.IsExported() {
-			return false
+			continue
+		}
+		if isGroup(sf) {
+			groupPrefix, ok := sf.Tag.Lookup(prefixTag)
+			if !ok {
+				groupPrefix = fieldNameToArg(sf.Name)
+			}
+			if groupPrefix != "" {
+				groupPrefix = prefix + groupPrefix + "-"
+			} else {
+				groupPrefix = prefix
+			}
+			c.addOptions(f, groupPrefix)
+			c.groups = append(c.groups, f)
+			continue
 		}
-		opt := optionFromField(sf)
+
+		opt, err := optionFromField(sf)
+		if err != nil {
+			c.problem(err)
+			continue
+		}
+		opt.Name = prefix + opt.Name
 		opt.Target = f
+		opt.ptr = c.fields[opt.Name]
+		if f.Kind() == reflect.Pointer && !isCustom(f.Type()) {
+			// options are parsed into a value of their own, and the field only points to it once set
+			opt.pointer, opt.ptr = f, nil
+			opt.Target = reflect.New(f.Type().Elem()).Elem()
+			if !f.IsNil() {
+				opt.Target.Set(f.Elem())
+			}
+			if opt.Default != "" {
+				c.problem(fmt.Errorf("pointer option %s can't have a default, it is nil until set", opt.Name))
+			}
+		}
+		if len(opt.Choices) == 0 {
+			opt.Choices = choicesOf(sf.Type)
+		}
+		if opt.Env == "" && c.cfg.autoEnv {
+			opt.Env = c.envName(&opt)
+		}
 
 		// Separate positional args and named options
 		if opt.Arg > 0 {
@@ -315,33 +739,83 @@ func (c *node) fromStruct(name string, target any) error {
 		} else {
 			c.options = append(c.options, opt)
 		}
+	}
+}
 
+// isGroup reports if the field is a struct of options rather than a single option.
+func isGroup(sf reflect.StructField) bool {
+	if sf.Type.Kind() != reflect.Struct || isCustom(sf.Type) {
 		return false
-	})
-
-	// Sort positional args by their arg position
-	sort.Slice(c.positionalOptions, func(i, j int) bool {
-		return c.positionalOptions[i].Arg < c.positionalOptions[j].Arg
-	})
+	}
+	_, isArg := sf.Tag.Lookup(argTag)
+	return !isArg
+}
 
+// bindGlobals registers the options of the struct pointed to by globals as shared with every descendant of c.
+func (c *node) bindGlobals(globals any) error {
+	v := reflect.ValueOf(globals)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: Globals() of %s must return a pointer to a struct, got %T", ErrInvalidType, c.name, globals)
+	}
+	// a detached node with the same path as c, so options derive the same env names
+	c.globals = &node{name: c.name, parent: c.parent, cfg: c.cfg, target: globals}
+	if g, ok := c.generated(globals); ok {
+		c.globals.fields = g.QuackFields()
+	}
+	c.globals.addOptions(v.Elem(), "")
+	if len(c.globals.positionalOptions) > 0 {
+		return fmt.Errorf("%w: Globals() of %s can't have positional arguments", ErrInvalidType, c.name)
+	}
+	c.globals.bindConstraints()
+	c.globals.applyDefaults()
 	return nil
 }
 
-// BindCobra a structure to a *cobra.Command (and sub-commands)
-func BindCobra(name string, root any) (*cobra.Command, error) {
-	rn := new(node)
-	err := rn.fromStruct(name, root)
-	if err != nil {
-		return nil, err
+// targetValue is the struct the node was bound from.
+func (c *node) targetValue() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.target))
+}
+
+// globalsOfType finds the globals of c or an ancestor that can be assigned to a field of type t.
+func (c *node) globalsOfType(t reflect.Type) *node {
+	for n := c; n != nil; n = n.parent {
+		if n.globals == nil {
+			continue
+		}
+		gt := n.globals.targetValue().Type()
+		if t == gt || t == reflect.PointerTo(gt) {
+			return n.globals
+		}
 	}
-	return rn.toCobra(), err
+	return nil
 }
 
-// MustBindCobra will panic if BindCobra returns an error
-func MustBindCobra(name string, root any) *cobra.Command {
-	cmd, err := BindCobra(name, root)
-	if err != nil {
-		panic(err)
+// injection is a field that receives the globals of an ancestor.
+type injection struct {
+	field   reflect.Value
+	globals *node
+}
+
+// injectGlobals points (or copies) the parsed globals into fields that asked for them.
+func (c *node) injectGlobals() {
+	for _, i := range c.injections {
+		if !i.field.CanSet() {
+			continue
+		}
+		if i.field.Kind() == reflect.Pointer {
+			i.field.Set(reflect.ValueOf(i.globals.target))
+		} else {
+			i.field.Set(i.globals.targetValue())
+		}
 	}
-	return cmd
+}
+
+type globalsKey struct {
+	t reflect.Type
+}
+
+// GetGlobals returns the globals of type T declared by a group above the running command, or nil.
+func GetGlobals[T any](ctx context.Context) *T {
+	g, _ := ctx.Value(globalsKey{reflect.TypeFor[T]()}).(*T)
+	return g
 }
@@ -0,0 +1,325 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// CobraCommand is the a comand that implements the cobra.Command.Run interface.
+// This is useful when you need lower level access to things like global options or the raw cli args.
+type CobraCommand interface {
+	Run(cmd *cobra.Command, args []string)
+}
+
+// BindCobra a structure to a *cobra.Command (and sub-commands)
+// The command's UsageFunc lists subcommands in the order they were given, see cobraUsage.
+func BindCobra(name string, root any, opts ...BindOption) (*cobra.Command, error) {
+	rn, err := bind(name, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd := rn.toCobra()
+	cmd.SetUsageFunc(cobraUsage(cmd))
+	return cmd, nil
+}
+
+// MustBindCobra will panic if BindCobra returns an error
+func MustBindCobra(name string, root any, opts ...BindOption) *cobra.Command {
+	cmd, err := BindCobra(name, root, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+func (c *node) toCobra() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:        c.name,
+		Long:       c.long,
+		Short:      c.short,
+		Aliases:    c.aliases,
+		Hidden:     c.hidden,
+		Deprecated: c.deprecated,
+	}
+	for i, s := range c.subcommands {
+		sub := s.toCobra()
+		sub.Annotations = map[string]string{orderAnnotation: strconv.Itoa(i)}
+		if s.category != "" {
+			if !cmd.ContainsGroup(s.category) {
+				cmd.AddGroup(&cobra.Group{ID: s.category, Title: s.category + ":"})
+			}
+			sub.GroupID = s.category
+		}
+		cmd.AddCommand(sub)
+	}
+	if c.parent == nil && c.cfg.completion {
+		cmd.CompletionOptions.DisableDefaultCmd = true
+		cmd.AddCommand(cobraCompletionCmd(cmd))
+	}
+	if c.pending {
+		c.deferCobra(cmd)
+	} else {
+		c.setupCobra(cmd)
+	}
+	return cmd
+}
+
+// orderAnnotation holds the position of a subcommand among those of its parent.
+const orderAnnotation = "quack_order"
+
+// defaultCobraUsage is the usage template of commands that weren't given one.
+var defaultCobraUsage = new(cobra.Command).UsageTemplate()
+
+// cobraUsage prints the usage of the commands under root like cobra does by default, but lists subcommands
+// in the order they were given, which is sorted already for a Map. cobra itself only keeps that order with
+// cobra.EnableCommandSorting turned off, which would change every other cobra command in the program too.
+// Commands given a usage template of their own are printed by cobra.
+func cobraUsage(root *cobra.Command) func(*cobra.Command) error {
+	var usage func(*cobra.Command) error
+	usage = func(cmd *cobra.Command) error {
+		if cmd.UsageTemplate() != defaultCobraUsage {
+			root.SetUsageFunc(nil)
+			defer root.SetUsageFunc(usage)
+			return cmd.UsageFunc()(cmd)
+		}
+		// merges in the persistent flags of the parents, as cobra does before printing usage
+		cmd.InheritedFlags()
+		w := cmd.OutOrStderr()
+		cmds := slices.Clone(cmd.Commands())
+		slices.SortStableFunc(cmds, func(a, b *cobra.Command) int {
+			return cmp.Compare(cobraOrder(a), cobraOrder(b))
+		})
+		listed := func(sub *cobra.Command) bool {
+			return sub.IsAvailableCommand() || sub.Name() == "help"
+		}
+		list := func(sub *cobra.Command) {
+			fmt.Fprintf(w, "\n  %-*s %s", sub.NamePadding(), sub.Name(), sub.Short)
+		}
+
+		fmt.Fprint(w, "Usage:")
+		if cmd.Runnable() {
+			fmt.Fprintf(w, "\n  %s", cmd.UseLine())
+		}
+		if cmd.HasAvailableSubCommands() {
+			fmt.Fprintf(w, "\n  %s [command]", cmd.CommandPath())
+		}
+		if len(cmd.Aliases) > 0 {
+			fmt.Fprintf(w, "\n\nAliases:\n  %s", cmd.NameAndAliases())
+		}
+		if cmd.HasExample() {
+			fmt.Fprintf(w, "\n\nExamples:\n%s", cmd.Example)
+		}
+		if cmd.HasAvailableSubCommands() {
+			if len(cmd.Groups()) == 0 {
+				fmt.Fprint(w, "\n\nAvailable Commands:")
+				for _, sub := range cmds {
+					if listed(sub) {
+						list(sub)
+					}
+				}
+			} else {
+				for _, group := range cmd.Groups() {
+					fmt.Fprintf(w, "\n\n%s", group.Title)
+					for _, sub := range cmds {
+						if sub.GroupID == group.ID && listed(sub) {
+							list(sub)
+						}
+					}
+				}
+				if !cmd.AllChildCommandsHaveGroup() {
+					fmt.Fprint(w, "\n\nAdditional Commands:")
+					for _, sub := range cmds {
+						if sub.GroupID == "" && listed(sub) {
+							list(sub)
+						}
+					}
+				}
+			}
+		}
+		if cmd.HasAvailableLocalFlags() {
+			fmt.Fprintf(w, "\n\nFlags:\n%s", strings.TrimRightFunc(cmd.LocalFlags().FlagUsages(), unicode.IsSpace))
+		}
+		if cmd.HasAvailableInheritedFlags() {
+			fmt.Fprintf(w, "\n\nGlobal Flags:\n%s", strings.TrimRightFunc(cmd.InheritedFlags().FlagUsages(), unicode.IsSpace))
+		}
+		if cmd.HasHelpSubCommands() {
+			fmt.Fprint(w, "\n\nAdditional help topics:")
+			for _, sub := range cmds {
+				if sub.IsAdditionalHelpTopicCommand() {
+					fmt.Fprintf(w, "\n  %-*s %s", sub.CommandPathPadding(), sub.CommandPath(), sub.Short)
+				}
+			}
+		}
+		if cmd.HasAvailableSubCommands() {
+			fmt.Fprintf(w, "\n\nUse \"%s [command] --help\" for more information about a command.", cmd.CommandPath())
+		}
+		fmt.Fprintln(w)
+		return nil
+	}
+	return usage
+}
+
+// cobraOrder is the position of cmd among the subcommands it was bound with.
+// Commands added by cobra or the program come after them.
+func cobraOrder(cmd *cobra.Command) int {
+	if i, err := strconv.Atoi(cmd.Annotations[orderAnnotation]); err == nil {
+		return i
+	}
+	return math.MaxInt
+}
+
+// deferCobra sets up cmd for a command left pending by WithLazy. Until it runs, cobra doesn't know its flags,
+// so cmd takes its arguments unparsed, then binds the command and runs the command line again.
+func (c *node) deferCobra(cmd *cobra.Command) {
+	cmd.DisableFlagParsing = true
+	cmd.RunE = func(cobraCmd *cobra.Command, args []string) error {
+		if err := c.loadCobra(cmd); err != nil {
+			return err
+		}
+		root := cmd.Root()
+		root.SetArgs(append(strings.Fields(cmd.CommandPath())[1:], args...))
+		// errors are printed once, when this run returns them, and so is the deprecation warning
+		silenceErrors, silenceUsage, deprecated := root.SilenceErrors, root.SilenceUsage, cmd.Deprecated
+		root.SilenceErrors, root.SilenceUsage, cmd.Deprecated = true, true, ""
+		defer func() {
+			root.SilenceErrors, root.SilenceUsage, cmd.Deprecated = silenceErrors, silenceUsage, deprecated
+		}()
+		return root.ExecuteContext(cobraCmd.Context())
+	}
+	// the help command shows the help of cmd without running it
+	cmd.SetHelpFunc(func(cobraCmd *cobra.Command, args []string) {
+		if err := c.loadCobra(cmd); err != nil {
+			cobraCmd.PrintErrln("Error:", err)
+			return
+		}
+		cmd.HelpFunc()(cmd, args)
+	})
+}
+
+// loadCobra binds c and the pending commands above it, and sets up their cobra commands.
+// cobra only runs the selected command, so the groups in between, whose globals c may use,
+// are loaded from here.
+func (c *node) loadCobra(cmd *cobra.Command) error {
+	if !c.pending {
+		return nil
+	}
+	if err := c.parent.loadCobra(cmd.Parent()); err != nil {
+		return err
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	cmd.DisableFlagParsing = false
+	cmd.RunE = nil
+	cmd.SetHelpFunc(nil)
+	c.setupCobra(cmd)
+	return nil
+}
+
+// setupCobra registers the flags of c on cmd and wires up running it.
+func (c *node) setupCobra(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	for _, o := range c.options {
+		o.setFlag(flags)
+	}
+	if c.globals != nil {
+		for _, o := range c.globals.options {
+			o.setFlag(cmd.PersistentFlags())
+		}
+	}
+	if c.parent == nil && c.cfg.configDefaults {
+		cmd.PersistentFlags().StringVar(&c.cfg.configPath, configFlag, "", "config file to load")
+	}
+	// pflag has no aliases, so they are normalized to the name of the flag they stand for
+	aliases := map[string]string{}
+	for _, o := range c.flagOptions() {
+		for _, alias := range o.Aliases {
+			aliases[alias] = o.Name
+		}
+	}
+	if len(aliases) > 0 {
+		normalize := func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+			if canonical, ok := aliases[name]; ok {
+				name = canonical
+			}
+			return pflag.NormalizedName(name)
+		}
+		flags.SetNormalizeFunc(normalize)
+		cmd.PersistentFlags().SetNormalizeFunc(normalize)
+	}
+	c.addCobraCompletions(cmd)
+
+	// Wrap the run function to handle positional arguments and validation
+	if c.run != nil {
+		originalRun := func(ctx context.Context, _ *cobra.Command, args []string) error {
+			return c.run(ctx, args)
+		}
+		if target, ok := c.target.(CobraCommand); ok {
+			originalRun = func(_ context.Context, cobraCmd *cobra.Command, args []string) error {
+				target.Run(cobraCmd, args)
+				return nil
+			}
+		}
+		if c.isGroup {
+			cmd.RunE = func(cobraCmd *cobra.Command, args []string) error {
+				ctx, err := c.prepare(cobraCmd.Context(), args, cobraChanged(cobraCmd.Flags()))
+				if err != nil {
+					return err
+				}
+				if err := originalRun(ctx, cobraCmd, args); err != nil {
+					return err
+				}
+				return cobraCmd.Help()
+			}
+		} else {
+			// cobra skips the post run hooks if RunE fails, so the After hooks are run from
+			// RunE in that case and must not run twice.
+			// The hooks are persistent so they can't be replaced by a parent's, but a root leaf still
+			// has cobra's completion and help subcommands, which must not run them.
+			var afterDone bool
+			cmd.PersistentPreRunE = func(cobraCmd *cobra.Command, args []string) error {
+				if cobraCmd != cmd {
+					return nil
+				}
+				afterDone = false
+				ctx, err := c.prepare(cobraCmd.Context(), args, cobraChanged(cobraCmd.Flags()))
+				if err != nil {
+					return err
+				}
+				cobraCmd.SetContext(ctx)
+				return c.before(ctx)
+			}
+			cmd.RunE = func(cobraCmd *cobra.Command, args []string) error {
+				if err := originalRun(cobraCmd.Context(), cobraCmd, args); err != nil {
+					afterDone = true
+					return c.after(cobraCmd.Context(), err)
+				}
+				return nil
+			}
+			cmd.PersistentPostRunE = func(cobraCmd *cobra.Command, args []string) error {
+				if cobraCmd != cmd || afterDone {
+					return nil
+				}
+				return c.after(cobraCmd.Context(), nil)
+			}
+		}
+	}
+}
+
+// cobraChanged reports if the named flag, or its negation, was passed on the command line.
+func cobraChanged(fs *pflag.FlagSet) func(name string) bool {
+	return func(name string) bool {
+		return fs.Changed(name) || fs.Changed(negationPrefix+name)
+	}
+}
@@ -0,0 +1,833 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	addBackend("cobra", func(opts ...BindOption) backend {
+		return func(t *testing.T, root any, args ...string) error {
+			cmd, err := BindCobra("app", root, opts...)
+			if err != nil {
+				return err
+			}
+			cmd.SetArgs(args)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return cmd.Execute()
+		}
+	})
+}
+
+func sanatize(s string) string {
+	remove := []string{
+		"\n",
+		"\t",
+		" ",
+	}
+	for _, x := range remove {
+		s = strings.ReplaceAll(s, x, "")
+	}
+	return s
+}
+
+type positionalCmd struct {
+	Source string `arg:"1"`
+	Target string `arg:"2"`
+}
+
+func (p *positionalCmd) Run(*cobra.Command, []string) {
+}
+
+type repeatedFlagCmd struct {
+	Files []string
+}
+
+func (r *repeatedFlagCmd) Run(*cobra.Command, []string) {
+}
+
+type repeatedPositionalCmd struct {
+	Files []string `arg:"1"`
+}
+
+func (r *repeatedPositionalCmd) Run(*cobra.Command, []string) {
+}
+
+func TestBindCobra(t *testing.T) {
+	simple := new(simpleCmd)
+	tests := []struct {
+		name  string
+		in    any
+		usage string
+		err   error
+	}{
+		{
+			"bad_type",
+			0,
+			"",
+			ErrInvalidType,
+		},
+		{
+			"not a command",
+			struct{}{},
+			"",
+			ErrNotACommand,
+		},
+		{
+			"simple",
+			simple,
+			`
+Usage:
+  simple [flags]
+
+Flags:
+ --an-int int       I am an int
+ --no-help string
+`,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := BindCobra(test.name, test.in)
+			if test.err == nil {
+				assert.Nil(t, err)
+				cmd.UsageString()
+				assert.Equal(t,
+					sanatize(test.usage), sanatize(cmd.UsageString()))
+				return
+			}
+			assert.ErrorIs(t, err, test.err)
+			assert.Nil(t, cmd)
+		})
+
+	}
+}
+
+type outOfOrderPositionalCmd struct {
+	Target string `arg:"2"`
+	Source string `arg:"1"`
+}
+
+func (o *outOfOrderPositionalCmd) Run(*cobra.Command, []string) {
+}
+
+func TestPositionalArgs(t *testing.T) {
+	t.Run("basic_positional", func(t *testing.T) {
+		cmd := new(positionalCmd)
+		cobraCmd, err := BindCobra("copy", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		// Simulate running the command with positional args
+		cobraCmd.SetArgs([]string{"file1.txt", "file2.txt"})
+		err = cobraCmd.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, "file1.txt", cmd.Source)
+		assert.Equal(t, "file2.txt", cmd.Target)
+	})
+
+	t.Run("out_of_order_positional", func(t *testing.T) {
+		cmd := new(outOfOrderPositionalCmd)
+		cobraCmd, err := BindCobra("copy", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		// Verify that arg positions are respected, not field order
+		cobraCmd.SetArgs([]string{"source.txt", "target.txt"})
+		err = cobraCmd.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, "source.txt", cmd.Source) // arg 1
+		assert.Equal(t, "target.txt", cmd.Target) // arg 2
+	})
+
+	t.Run("repeated_positional", func(t *testing.T) {
+		cmd := new(repeatedPositionalCmd)
+		cobraCmd, err := BindCobra("list", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		// Simulate running the command with multiple positional args
+		cobraCmd.SetArgs([]string{"file1.txt", "file2.txt", "file3.txt"})
+		err = cobraCmd.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"file1.txt", "file2.txt", "file3.txt"}, cmd.Files)
+	})
+}
+
+func TestRepeatedFlags(t *testing.T) {
+	t.Run("repeated_flag", func(t *testing.T) {
+		cmd := new(repeatedFlagCmd)
+		cobraCmd, err := BindCobra("process", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		// Simulate running the command with repeated flags
+		cobraCmd.SetArgs([]string{"--files", "file1.txt", "--files", "file2.txt"})
+		err = cobraCmd.Execute()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"file1.txt", "file2.txt"}, cmd.Files)
+	})
+}
+
+// cmdWithValidatingOption has an option that implements Validator but the command doesn't
+type cmdWithValidatingOption struct {
+	Option validatingOption
+}
+
+func (c *cmdWithValidatingOption) Run(*cobra.Command, []string) {
+}
+
+// cmdWithValidatingOptionAndCommandValidator has both option and command validation
+type cmdWithValidatingOptionAndCommandValidator struct {
+	Option validatingOption
+}
+
+func (c *cmdWithValidatingOptionAndCommandValidator) Run(*cobra.Command, []string) {
+}
+
+func (c *cmdWithValidatingOptionAndCommandValidator) Validate() error {
+	// Command level validation - options should not be validated individually
+	return fmt.Errorf("command validation error")
+}
+
+func TestOptionValidation(t *testing.T) {
+	t.Run("valid_option", func(t *testing.T) {
+		cmd := &cmdWithValidatingOption{Option: "valid"}
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		cobraCmd.SetArgs([]string{"--option", "valid"})
+		err = cobraCmd.Execute()
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid_option", func(t *testing.T) {
+		cmd := &cmdWithValidatingOption{Option: "valid"}
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		cobraCmd.SetArgs([]string{"--option", "invalid"})
+		err = cobraCmd.Execute()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "validation failed for option")
+	})
+
+	t.Run("command_implements_validator_skips_option_validation", func(t *testing.T) {
+		cmd := &cmdWithValidatingOptionAndCommandValidator{Option: "invalid"}
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+		assert.NotNil(t, cobraCmd)
+
+		// Even though option is "invalid", it should not be validated
+		// because the command itself implements Validator
+		cobraCmd.SetArgs([]string{"--option", "invalid"})
+		err = cobraCmd.Execute()
+		// Should succeed without option validation error
+		assert.Nil(t, err)
+	})
+}
+
+func TestErrorCommands(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	t.Run("run_e_success", func(t *testing.T) {
+		cmd := new(errCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{})
+		err = cobraCmd.ExecuteContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "value", cmd.ctx.Value(ctxKey{}))
+	})
+
+	t.Run("run_e_failure", func(t *testing.T) {
+		cmd := new(errCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--fail"})
+		cobraCmd.SilenceUsage = true
+		cobraCmd.SilenceErrors = true
+		err = cobraCmd.ExecuteContext(ctx)
+		assert.EqualError(t, err, "run failed")
+	})
+
+	t.Run("run_context_failure", func(t *testing.T) {
+		cmd := new(errSimpleCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--fail"})
+		cobraCmd.SilenceUsage = true
+		cobraCmd.SilenceErrors = true
+		err = cobraCmd.ExecuteContext(ctx)
+		assert.EqualError(t, err, "run failed")
+		assert.Equal(t, "value", cmd.ctx.Value(ctxKey{}))
+	})
+}
+
+func TestDefaulter(t *testing.T) {
+	t.Run("defaults_in_help", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(defaulterCmd))
+		assert.Nil(t, err)
+
+		usage := cobraCmd.UsageString()
+		assert.Contains(t, usage, `(default "localhost")`)
+		assert.Contains(t, usage, `(default 8080)`)
+		assert.Contains(t, usage, `(default [a,b])`)
+		assert.Contains(t, usage, `(default "from-type")`)
+		assert.Contains(t, usage, `(default "from-tag")`)
+	})
+
+	t.Run("defaults_applied", func(t *testing.T) {
+		cmd := new(defaulterCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.True(t, cmd.Touched)
+		assert.Equal(t, "localhost", cmd.Host)
+		assert.Equal(t, 8080, cmd.Port)
+		assert.Equal(t, []string{"a", "b"}, cmd.Tags)
+		assert.Equal(t, defaultedOption("from-type"), cmd.Level)
+		assert.Equal(t, defaultedOption("from-tag"), cmd.Tagged)
+		assert.Equal(t, "here", cmd.Target)
+	})
+
+	t.Run("user_input_wins", func(t *testing.T) {
+		cmd := new(defaulterCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--port", "9000", "--tags", "c", "--level", "x", "there"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, 9000, cmd.Port)
+		assert.Equal(t, []string{"c"}, cmd.Tags)
+		assert.Equal(t, defaultedOption("x"), cmd.Level)
+		assert.Equal(t, "there", cmd.Target)
+	})
+}
+
+func TestCustomTypes(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(customTypesCmd))
+		assert.Nil(t, err)
+
+		usage := cobraCmd.UsageString()
+		assert.Contains(t, usage, "--version version")
+		assert.Contains(t, usage, "(default v1.2)")
+		assert.Contains(t, usage, "--level level")
+		assert.Contains(t, usage, "--addr ip")
+		assert.Contains(t, usage, "--versions versionSlice")
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		cmd := new(customTypesCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{
+			"--version", "v2.3",
+			"--level", "high",
+			"--addr", "10.0.0.1",
+			"--versions", "v1.0", "--versions", "v1.1",
+			"v3.0", "v4.0", "v4.1",
+		})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, version{2, 3}, cmd.Version)
+		assert.Equal(t, level(2), cmd.Level)
+		assert.Equal(t, "10.0.0.1", cmd.Addr.String())
+		assert.Equal(t, []version{{1, 0}, {1, 1}}, cmd.Versions)
+		assert.Equal(t, version{3, 0}, cmd.Target)
+		assert.Equal(t, []version{{4, 0}, {4, 1}}, cmd.Rest)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		cmd := new(customTypesCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"v3.0", "v4.0"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, version{1, 2}, cmd.Version)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(customTypesCmd))
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--level", "medium", "v3.0", "v4.0"})
+		cobraCmd.SilenceUsage = true
+		cobraCmd.SilenceErrors = true
+		err = cobraCmd.Execute()
+		assert.ErrorContains(t, err, `unknown level "medium"`)
+	})
+}
+
+func TestEnv(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(envCmd))
+		assert.Nil(t, err)
+
+		usage := cobraCmd.UsageString()
+		assert.Contains(t, usage, "port to use [$TEST_PORT]")
+		assert.Contains(t, usage, "[$TEST_HOSTS]")
+	})
+
+	t.Run("env_over_default", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "9000")
+		t.Setenv("TEST_HOSTS", "a,b")
+		t.Setenv("TEST_NAME", "from-env")
+		cmd := new(envCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, 9000, cmd.Port)
+		assert.Equal(t, []string{"a", "b"}, cmd.Hosts)
+		assert.Equal(t, "from-env", cmd.Name)
+	})
+
+	t.Run("flag_over_env", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "9000")
+		t.Setenv("TEST_NAME", "from-env")
+		cmd := new(envCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--port", "10", "from-args"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, 10, cmd.Port)
+		assert.Equal(t, "from-args", cmd.Name)
+	})
+
+	t.Run("separator", func(t *testing.T) {
+		t.Setenv("TEST_HOSTS", "a:b:c")
+		t.Setenv("TEST_NAME", "x")
+		cmd := new(envCmd)
+		cobraCmd, err := BindCobra("test", cmd, WithEnvSeparator(":"))
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, []string{"a", "b", "c"}, cmd.Hosts)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "abc")
+		cobraCmd, err := BindCobra("test", new(envCmd))
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"x"})
+		cobraCmd.SilenceUsage = true
+		cobraCmd.SilenceErrors = true
+		assert.ErrorContains(t, cobraCmd.Execute(), "failed to parse port from $TEST_PORT")
+	})
+
+	t.Run("auto_env", func(t *testing.T) {
+		t.Setenv("MYAPP_SERVE_LISTEN_PORT", "9000")
+		t.Setenv("CUSTOM_HOST", "example.com")
+		cmd := new(autoEnvCmd)
+		cobraCmd, err := BindCobra("myapp", Map{"serve": cmd}, WithAutoEnv())
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"serve"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, 9000, cmd.ListenPort)
+		assert.Equal(t, "example.com", cmd.Host)
+	})
+
+	t.Run("env_prefix", func(t *testing.T) {
+		t.Setenv("OTHER_SERVE_LISTEN_PORT", "9000")
+		cmd := new(autoEnvCmd)
+		cobraCmd, err := BindCobra("myapp", Map{"serve": cmd}, WithEnvPrefix("other"))
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"serve"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, 9000, cmd.ListenPort)
+	})
+}
+
+func TestOptionGroups(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(groupedCmd))
+		assert.Nil(t, err)
+
+		usage := cobraCmd.UsageString()
+		assert.Contains(t, usage, "-l, --level string")
+		assert.Contains(t, usage, "--token string")
+		assert.Contains(t, usage, "--db-host string")
+		assert.Contains(t, usage, "--db-port int")
+		assert.Contains(t, usage, "(default 5432)")
+		assert.Contains(t, usage, "--ro-host string")
+		assert.Contains(t, usage, "--region string")
+		assert.NotContains(t, usage, "skipped")
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		cmd := new(groupedCmd)
+		cobraCmd, err := BindCobra("test", cmd)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"-l", "debug", "--token", "abc", "--db-host", "db", "--ro-port", "1"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Equal(t, "debug", cmd.Level)
+		assert.Equal(t, "abc", cmd.authConfig.Token)
+		assert.Equal(t, "db", cmd.DB.Host)
+		assert.Equal(t, 5432, cmd.DB.Port)
+		assert.Equal(t, "localhost", cmd.Replica.Host)
+		assert.Equal(t, 1, cmd.Replica.Port)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", new(groupedCmd))
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"--db-host", ""})
+		cobraCmd.SilenceErrors = true
+		cobraCmd.SilenceUsage = true
+		assert.ErrorContains(t, cobraCmd.Execute(), "host is required")
+	})
+}
+
+func TestGlobals(t *testing.T) {
+	t.Run("help", func(t *testing.T) {
+		cobraCmd, err := BindCobra("test", newGlobalsRoot())
+		assert.Nil(t, err)
+
+		leaf, _, err := cobraCmd.Find([]string{"leaf"})
+		assert.Nil(t, err)
+		usage := leaf.UsageString()
+		assert.Contains(t, usage, "Global Flags:")
+		assert.Contains(t, usage, "-v, --verbose")
+		assert.Contains(t, usage, "--name string")
+		assert.NotContains(t, usage, "--globals")
+		assert.NotContains(t, usage, "--shared")
+	})
+
+	t.Run("injection", func(t *testing.T) {
+		root := newGlobalsRoot()
+		cobraCmd, err := BindCobra("test", root)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"-v", "leaf", "--name", "x"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.True(t, root.Opts.Verbose)
+		assert.Equal(t, globalOpts{Verbose: true, Endpoint: "https://example.com"}, root.leaf.Globals)
+		assert.Same(t, &root.Opts, root.leaf.Shared)
+		assert.Equal(t, "x", root.leaf.Name)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Setenv("TEST_ENDPOINT", "https://env.com")
+		root := newGlobalsRoot()
+		cobraCmd, err := BindCobra("test", root)
+		assert.Nil(t, err)
+
+		cobraCmd.SetArgs([]string{"mid", "ctx", "--verbose"})
+		assert.Nil(t, cobraCmd.Execute())
+		assert.Same(t, &root.Opts, root.ctx.globals)
+		assert.True(t, root.ctx.globals.Verbose)
+		assert.Equal(t, "https://env.com", root.ctx.globals.Endpoint)
+	})
+}
+
+func TestCobraDeprecated(t *testing.T) {
+	const want = "Command \"old\" is deprecated, use serve instead\n"
+	const wantFlag = "Flag --legacy has been deprecated, use --port instead\n"
+
+	var out bytes.Buffer
+	for _, opts := range [][]BindOption{nil, {WithLazy()}} {
+		out.Reset()
+		cmd, err := BindCobra("app", &renameRoot{}, opts...)
+		assert.NoError(t, err)
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"old"})
+		assert.NoError(t, cmd.Execute())
+		assert.Equal(t, want, out.String())
+
+		out.Reset()
+		cmd, err = BindCobra("app", &renameRoot{}, opts...)
+		assert.NoError(t, err)
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"serve", "--legacy", "x"})
+		assert.NoError(t, cmd.Execute())
+		assert.Equal(t, wantFlag, out.String())
+	}
+}
+
+func TestCobraHidden(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindCobra("app", &renameRoot{})
+	assert.NoError(t, err)
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"serve", "--help"})
+	assert.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "--port")
+	assert.Contains(t, out.String(), "start")
+	assert.NotContains(t, out.String(), "--verbose")
+	assert.NotContains(t, out.String(), "--legacy")
+}
+
+func TestChoicesComplete(t *testing.T) {
+	cmd, err := BindCobra("app", Map{"export": &exportCmd{}})
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"__complete", "export", "--format", "y"})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, []string{"yaml", ":4"}, strings.Fields(out.String()))
+
+	out.Reset()
+	cmd.SetArgs([]string{"__complete", "export", "--format", "json", ""})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, []string{"stdout", "file", ":4"}, strings.Fields(out.String()))
+}
+
+func TestCobraChoicesHelp(t *testing.T) {
+	cmd, err := BindCobra("app", &exportCmd{})
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	fmtUsage(&out, cmd.Flags())
+	assert.Contains(t, out.String(), "(default='table')")
+	assert.Contains(t, out.String(), "output format (one of json, yaml, table)")
+}
+
+func TestCobraNegateHelp(t *testing.T) {
+	cmd, err := BindCobra("app", &toggleCmd{})
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	fmtUsage(&out, cmd.Flags())
+	assert.Regexp(t, `--\[no-\]cache +\(default=true\)`, out.String())
+	assert.Regexp(t, `-v, --verbose +\(default=0\)`, out.String())
+	assert.NotContains(t, out.String(), "--no-cache")
+	assert.NotContains(t, out.String(), "count")
+}
+
+func TestConstraintsHelp(t *testing.T) {
+	cmd, err := BindCobra("test", new(constrainedCmd))
+	assert.Nil(t, err)
+
+	usage := cmd.UsageString()
+	assert.Contains(t, usage, "--name string       (required)")
+	assert.Contains(t, usage, "--json              (conflicts with --yaml)")
+	assert.Contains(t, usage, "--password string   (requires --user) [$TEST_PASSWORD]")
+	assert.Contains(t, usage, "--tls-cert string   (requires --tls-key)")
+}
+
+func TestConstraintsGlobalsHelp(t *testing.T) {
+	cmd, err := BindCobra("app", &tlsRoot{})
+	assert.Nil(t, err)
+	serve, _, err := cmd.Find([]string{"serve"})
+	assert.Nil(t, err)
+	usage := serve.UsageString()
+	assert.Contains(t, usage, "(requires --tls-key)")
+	assert.Contains(t, usage, "(conflicts with --token)")
+}
+
+func TestLazyHelp(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindCobra("app", &lazyRoot{}, WithLazy())
+	assert.NoError(t, err)
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--help"})
+	assert.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "serve things")
+
+	for _, args := range [][]string{{"serve", "--help"}, {"help", "serve"}} {
+		out.Reset()
+		cmd, err := BindCobra("app", &lazyRoot{}, WithLazy())
+		assert.NoError(t, err)
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		assert.NoError(t, cmd.Execute())
+		assert.Contains(t, out.String(), "--port", args)
+	}
+}
+
+type benchLeaf struct {
+	Host    string        `default:"localhost" help:"host to connect to"`
+	Port    int           `short:"p" default:"80"`
+	Verbose bool          `short:"v"`
+	Tags    []string      `help:"tags to apply"`
+	Timeout time.Duration `default:"1s"`
+	Target  string        `arg:"1" default:"all"`
+}
+
+func (benchLeaf) Run([]string) {}
+
+type benchGroup struct{}
+
+func (benchGroup) SubCommands() Map {
+	m := Map{}
+	for i := range 100 {
+		m[fmt.Sprintf("c%d", i)] = &benchLeaf{}
+	}
+	return m
+}
+
+// benchRoot is a tree of 1,000 commands, in 10 groups.
+type benchRoot struct{}
+
+func (benchRoot) SubCommands() Map {
+	m := Map{}
+	for i := range 10 {
+		m[fmt.Sprintf("g%d", i)] = &benchGroup{}
+	}
+	return m
+}
+
+// BenchmarkBind binds a large tree and runs one of its commands.
+func BenchmarkBind(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []BindOption
+	}{
+		{"eager", nil},
+		{"lazy", []BindOption{WithLazy()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			for b.Loop() {
+				cmd, err := BindCobra("app", &benchRoot{}, mode.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+				cmd.SetArgs([]string{"g3", "c42", "--port", "8080"})
+				if err := cmd.Execute(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCobraUsage(t *testing.T) {
+	cmd, err := BindCobra("app", &orderedRoot{})
+	assert.NoError(t, err)
+	// the order is kept without changing how other cobra commands are sorted
+	assert.True(t, cobra.EnableCommandSorting)
+
+	cmd.SetUsageTemplate("{{range .Commands}}{{.Name}} {{end}}\n")
+	assert.Equal(t, "backup migrate start stop version \n", cmd.UsageString())
+	sub, _, err := cmd.Find([]string{"stop"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\n", sub.UsageString())
+}
+
+func TestCobraCategories(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindCobra("app", &orderedRoot{})
+	assert.NoError(t, err)
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--help"})
+	assert.NoError(t, cmd.Execute())
+
+	order, categories := helpOrder(out.String())
+	// cobra lists the commands without a group last
+	assert.Equal(t, []string{"stop", "start", "migrate", "backup", "version"}, order)
+	assert.Equal(t, []string{"Server", "Database", "Maintenance"}, categories)
+}
+
+func cobraComplete(t *testing.T, args ...string) []string {
+	cmd, err := BindCobra("app", &completionRoot{}, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs(append([]string{"__complete"}, args...))
+	assert.Nil(t, cmd.Execute())
+	// drop the trailing directive line
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	return lines[:len(lines)-1]
+}
+
+func TestCobraCompleteFlag(t *testing.T) {
+	assert.Equal(t, []string{"us-east", "us-west"}, cobraComplete(t, "deploy", "--region", "us-"))
+}
+
+func TestCobraCompleteArg(t *testing.T) {
+	assert.Equal(t, []string{"api", "web"}, cobraComplete(t, "deploy", ""))
+}
+
+func TestCobraCompleteGlobal(t *testing.T) {
+	assert.Equal(t, []string{"eu-central"}, cobraComplete(t, "deploy", "--zone", "eu"))
+}
+
+func TestCobraCompleteArgDefault(t *testing.T) {
+	cmd, err := BindCobra("app", &completionRoot{}, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	// an argument without a completer is left to the shell, which completes file names
+	cmd.SetArgs([]string{"__complete", "copy", ""})
+	assert.Nil(t, cmd.Execute())
+	assert.Equal(t, ":0", strings.TrimSpace(out.String()))
+
+	out.Reset()
+	cmd.SetArgs([]string{"__complete", "copy", "src", ""})
+	assert.Nil(t, cmd.Execute())
+	assert.Equal(t, ":4", strings.TrimSpace(out.String()))
+}
+
+func TestCobraCompleteFilePath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "deploy.sh"), nil, 0o644))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "scripts"), 0o755))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "deploy.sh"),
+		filepath.Join(dir, "scripts") + string(filepath.Separator),
+	}, cobraComplete(t, "deploy", "--script", filepath.Join(dir, "")+string(filepath.Separator)))
+}
+
+func TestCobraCompletionCmd(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		cmd, err := BindCobra("app", &completionRoot{}, WithCompletion())
+		assert.Nil(t, err)
+		out := &bytes.Buffer{}
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"completion", shell})
+		assert.Nil(t, cmd.Execute(), shell)
+		assert.Contains(t, out.String(), "app", shell)
+	}
+}
+
+func TestCobraCompletionCmdDisabled(t *testing.T) {
+	cmd, err := BindCobra("app", &completionRoot{})
+	assert.Nil(t, err)
+	for _, s := range cmd.Commands() {
+		assert.NotEqual(t, "completion [bash|zsh|fish|powershell]", s.Use)
+	}
+}
+
+func TestCobraCompletionCmdRootLeaf(t *testing.T) {
+	leaf := &completionLeaf{}
+	cmd, err := BindCobra("app", leaf, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"completion", "bash"})
+	assert.Nil(t, cmd.Execute())
+	assert.Contains(t, out.String(), "app")
+
+	cmd.SetArgs([]string{"__complete", "--name", ""})
+	assert.Nil(t, cmd.Execute())
+	assert.Zero(t, leaf.before)
+}
@@ -1,12 +1,14 @@
 package quack
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"net"
 	"testing"
 
 	"github.com/spf13/cobra"
-	"github.com/stretchr/testify/assert"
 )
 
 type simpleCmd struct {
@@ -22,225 +24,263 @@ func (s *simpleCmd) Help() string {
 	return "longer help message"
 }
 
-func sanatize(s string) string {
-	remove := []string{
-		"\n",
-		"\t",
-		" ",
+// Test option validation
+
+// validatingOption is a custom type that implements Validator
+type validatingOption string
+
+func (v validatingOption) Validate() error {
+	if v == "invalid" {
+		return fmt.Errorf("option value is invalid")
 	}
-	for _, x := range remove {
-		s = strings.ReplaceAll(s, x, "")
+	return nil
+}
+
+type errCmd struct {
+	Fail bool
+	ctx  context.Context
+}
+
+func (e *errCmd) RunE(ctx context.Context, args []string) error {
+	e.ctx = ctx
+	if e.Fail {
+		return errors.New("run failed")
 	}
-	return s
+	return nil
 }
 
-type positionalCmd struct {
-	Source string `arg:"1"`
-	Target string `arg:"2"`
+type errSimpleCmd struct {
+	Fail bool
+	ctx  context.Context
 }
 
-func (p *positionalCmd) Run(*cobra.Command, []string) {
+func (e *errSimpleCmd) RunContext(ctx context.Context) error {
+	e.ctx = ctx
+	if e.Fail {
+		return errors.New("run failed")
+	}
+	return nil
 }
 
-type repeatedFlagCmd struct {
-	Files []string
+type ctxKey struct{}
+
+// defaultedOption sets its own default value
+type defaultedOption string
+
+func (d *defaultedOption) Default() {
+	*d = "from-type"
 }
 
-func (r *repeatedFlagCmd) Run(*cobra.Command, []string) {
+type defaulterCmd struct {
+	Host    string `default:"localhost"`
+	Port    int    `default:"80"`
+	Tags    []string
+	Level   defaultedOption
+	Tagged  defaultedOption `default:"from-tag"`
+	Target  string          `arg:"1"`
+	Touched bool
 }
 
-type repeatedPositionalCmd struct {
-	Files []string `arg:"1"`
+func (d *defaulterCmd) Default() {
+	d.Port = 8080
+	d.Tags = []string{"a", "b"}
+	d.Target = "here"
 }
 
-func (r *repeatedPositionalCmd) Run(*cobra.Command, []string) {
+func (d *defaulterCmd) RunE(context.Context, []string) error {
+	d.Touched = true
+	return nil
 }
 
-func TestBindCobra(t *testing.T) {
-	simple := new(simpleCmd)
-	tests := []struct {
-		name  string
-		in    any
-		usage string
-		err   error
-	}{
-		{
-			"bad_type",
-			0,
-			"",
-			ErrInvalidType,
-		},
-		{
-			"not a command",
-			struct{}{},
-			"",
-			ErrNotACommand,
-		},
-		{
-			"simple",
-			simple,
-			`
-Usage:
-  simple [flags]
-
-Flags:
- --an-int int       I am an int
- --no-help string
-`,
-			nil,
-		},
+// version implements Parser and fmt.Stringer
+type version struct {
+	Major, Minor int
+}
+
+func (v *version) Parse(s string) error {
+	_, err := fmt.Sscanf(s, "v%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+// level implements pflag.Value
+type level int
+
+func (l *level) Set(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", s)
 	}
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			cmd, err := BindCobra(test.name, test.in)
-			if test.err == nil {
-				assert.Nil(t, err)
-				cmd.UsageString()
-				assert.Equal(t,
-					sanatize(test.usage), sanatize(cmd.UsageString()))
-				return
-			}
-			assert.ErrorIs(t, err, test.err)
-			assert.Nil(t, cmd)
-		})
+	return nil
+}
 
+func (l *level) String() string {
+	if *l == 2 {
+		return "high"
 	}
+	return "low"
 }
 
-type outOfOrderPositionalCmd struct {
-	Target string `arg:"2"`
-	Source string `arg:"1"`
-}
-
-func (o *outOfOrderPositionalCmd) Run(*cobra.Command, []string) {
-}
-
-func TestPositionalArgs(t *testing.T) {
-	t.Run("basic_positional", func(t *testing.T) {
-		cmd := new(positionalCmd)
-		cobraCmd, err := BindCobra("copy", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
-
-		// Simulate running the command with positional args
-		cobraCmd.SetArgs([]string{"file1.txt", "file2.txt"})
-		err = cobraCmd.Execute()
-		assert.Nil(t, err)
-		assert.Equal(t, "file1.txt", cmd.Source)
-		assert.Equal(t, "file2.txt", cmd.Target)
-	})
-
-	t.Run("out_of_order_positional", func(t *testing.T) {
-		cmd := new(outOfOrderPositionalCmd)
-		cobraCmd, err := BindCobra("copy", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
-
-		// Verify that arg positions are respected, not field order
-		cobraCmd.SetArgs([]string{"source.txt", "target.txt"})
-		err = cobraCmd.Execute()
-		assert.Nil(t, err)
-		assert.Equal(t, "source.txt", cmd.Source) // arg 1
-		assert.Equal(t, "target.txt", cmd.Target) // arg 2
-	})
-
-	t.Run("repeated_positional", func(t *testing.T) {
-		cmd := new(repeatedPositionalCmd)
-		cobraCmd, err := BindCobra("list", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
-
-		// Simulate running the command with multiple positional args
-		cobraCmd.SetArgs([]string{"file1.txt", "file2.txt", "file3.txt"})
-		err = cobraCmd.Execute()
-		assert.Nil(t, err)
-		assert.Equal(t, []string{"file1.txt", "file2.txt", "file3.txt"}, cmd.Files)
-	})
-}
-
-func TestRepeatedFlags(t *testing.T) {
-	t.Run("repeated_flag", func(t *testing.T) {
-		cmd := new(repeatedFlagCmd)
-		cobraCmd, err := BindCobra("process", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
-
-		// Simulate running the command with repeated flags
-		cobraCmd.SetArgs([]string{"--files", "file1.txt", "--files", "file2.txt"})
-		err = cobraCmd.Execute()
-		assert.Nil(t, err)
-		assert.Equal(t, []string{"file1.txt", "file2.txt"}, cmd.Files)
-	})
+func (l *level) Type() string {
+	return "level"
 }
 
-// Test option validation
+type customTypesCmd struct {
+	Version  version `default:"v1.2"`
+	Level    level
+	Addr     net.IP
+	Versions []version
+	Target   version   `arg:"1"`
+	Rest     []version `arg:"2"`
+}
 
-// validatingOption is a custom type that implements Validator
-type validatingOption string
+func (c *customTypesCmd) Run([]string) {
+}
 
-func (v validatingOption) Validate() error {
-	if v == "invalid" {
-		return fmt.Errorf("option value is invalid")
+type envCmd struct {
+	Port  int      `env:"TEST_PORT" default:"80" help:"port to use"`
+	Hosts []string `env:"TEST_HOSTS"`
+	Name  string   `arg:"1" env:"TEST_NAME"`
+}
+
+func (e *envCmd) Run([]string) {
+}
+
+type autoEnvCmd struct {
+	ListenPort int
+	Host       string `env:"CUSTOM_HOST"`
+}
+
+func (a *autoEnvCmd) Run([]string) {
+}
+
+type dbConfig struct {
+	Host string `default:"localhost"`
+	Port int
+}
+
+func (d *dbConfig) Default() {
+	d.Port = 5432
+}
+
+func (d *dbConfig) Validate() error {
+	if d.Host == "" {
+		return errors.New("host is required")
 	}
 	return nil
 }
 
-// cmdWithValidatingOption has an option that implements Validator but the command doesn't
-type cmdWithValidatingOption struct {
-	Option validatingOption
+type LogConfig struct {
+	Level string `short:"l" default:"info"`
+}
+
+type authConfig struct {
+	Token string
+}
+
+type regionConfig struct {
+	Region string
+}
+
+type groupedCmd struct {
+	LogConfig
+	authConfig
+	DB      dbConfig
+	Replica dbConfig     `prefix:"ro"`
+	Flat    regionConfig `prefix:""`
+	Skipped dbConfig     `ignore:""`
+}
+
+func (g *groupedCmd) Run([]string) {
+}
+
+type globalOpts struct {
+	Verbose  bool   `short:"v"`
+	Endpoint string `default:"https://example.com" env:"TEST_ENDPOINT"`
+	Tags     []string
+}
+
+type globalsRoot struct {
+	Opts globalOpts
+	leaf *globalsLeaf
+	ctx  *globalsCtxLeaf
 }
 
-func (c *cmdWithValidatingOption) Run(*cobra.Command, []string) {
+func (g *globalsRoot) Globals() any {
+	return &g.Opts
 }
 
-// cmdWithValidatingOptionAndCommandValidator has both option and command validation
-type cmdWithValidatingOptionAndCommandValidator struct {
-	Option validatingOption
+func (g *globalsRoot) SubCommands() Map {
+	return Map{
+		"leaf": g.leaf,
+		"mid":  Map{"ctx": g.ctx},
+	}
 }
 
-func (c *cmdWithValidatingOptionAndCommandValidator) Run(*cobra.Command, []string) {
+type globalsLeaf struct {
+	Globals globalOpts
+	Shared  *globalOpts
+	Name    string
 }
 
-func (c *cmdWithValidatingOptionAndCommandValidator) Validate() error {
-	// Command level validation - options should not be validated individually
-	return fmt.Errorf("command validation error")
+func (g *globalsLeaf) Run([]string) {
 }
 
-func TestOptionValidation(t *testing.T) {
-	t.Run("valid_option", func(t *testing.T) {
-		cmd := &cmdWithValidatingOption{Option: "valid"}
-		cobraCmd, err := BindCobra("test", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
+type globalsCtxLeaf struct {
+	globals *globalOpts
+}
 
-		cobraCmd.SetArgs([]string{"--option", "valid"})
-		err = cobraCmd.Execute()
-		assert.Nil(t, err)
-	})
+func (g *globalsCtxLeaf) RunContext(ctx context.Context) error {
+	g.globals = GetGlobals[globalOpts](ctx)
+	return nil
+}
 
-	t.Run("invalid_option", func(t *testing.T) {
-		cmd := &cmdWithValidatingOption{Option: "valid"}
-		cobraCmd, err := BindCobra("test", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
+func newGlobalsRoot() *globalsRoot {
+	return &globalsRoot{leaf: new(globalsLeaf), ctx: new(globalsCtxLeaf)}
+}
 
-		cobraCmd.SetArgs([]string{"--option", "invalid"})
-		err = cobraCmd.Execute()
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "validation failed for option")
-	})
+// backend binds root and runs it with args, returning the error of the bind or the run.
+type backend = func(t *testing.T, root any, args ...string) error
 
-	t.Run("command_implements_validator_skips_option_validation", func(t *testing.T) {
-		cmd := &cmdWithValidatingOptionAndCommandValidator{Option: "invalid"}
-		cobraCmd, err := BindCobra("test", cmd)
-		assert.Nil(t, err)
-		assert.NotNil(t, cobraCmd)
+// frameworkBackends are the backends that a build tag can leave out, added by the tests built with them.
+var frameworkBackends = map[string]func(opts ...BindOption) backend{}
 
-		// Even though option is "invalid", it should not be validated
-		// because the command itself implements Validator
-		cobraCmd.SetArgs([]string{"--option", "invalid"})
-		err = cobraCmd.Execute()
-		// Should succeed without option validation error
-		assert.Nil(t, err)
-	})
+// addBackend adds a framework backend to backends and backendsWith.
+func addBackend(name string, with func(opts ...BindOption) backend) {
+	frameworkBackends[name] = with
+	backends[name] = with()
+}
+
+// backends runs the same arguments through every backend, returning the error of the bind or the run.
+var backends = backendsWith()
+
+// backendsWith is backends, binding with opts.
+func backendsWith(opts ...BindOption) map[string]backend {
+	b := map[string]backend{
+		"native": func(t *testing.T, root any, args ...string) error {
+			return Run(context.Background(), "app", root, args, append([]BindOption{WithOutput(io.Discard)}, opts...)...)
+		},
+		"flag": func(t *testing.T, root any, args ...string) error {
+			fs, run, err := BindFlagSet("app", root, opts...)
+			if err != nil {
+				return err
+			}
+			fs.SetOutput(io.Discard)
+			if err := fs.Parse(args); err != nil {
+				return err
+			}
+			return run(context.Background())
+		},
+	}
+	for name, with := range frameworkBackends {
+		b[name] = with(opts...)
+	}
+	return b
 }
@@ -1,30 +1,111 @@
+//go:build !quack_nourfave
+
 package quack
 
 import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/urfave/cli/v3"
 )
 
 // toUrfaveCommand converts a node to a *cli.Command
-func (c *node) toUrfaveCommand() *cli.Command {
+func (c *node) toUrfaveCommand() (*cli.Command, error) {
 	cmd := &cli.Command{
-		Name:  c.name,
-		Usage: c.short,
+		Name:    c.name,
+		Usage:   c.short,
+		Aliases: c.aliases,
+		Hidden:  !c.inHelp(),
 	}
 	if c.long != "" {
 		cmd.Description = c.long
 	}
 
-	// Set flags
-	cmd.Flags = c.toUrfaveFlags()
-
 	// Set subcommands
 	for _, s := range c.subcommands {
-		cmd.Commands = append(cmd.Commands, s.toUrfaveCommand())
+		sub, err := s.toUrfaveCommand()
+		if err != nil {
+			return nil, err
+		}
+		sub.Category = s.category
+		cmd.Commands = append(cmd.Commands, sub)
+		if s.pending {
+			cmd.SuggestCommandFunc = c.loadUrfave
+		}
+	}
+	if c.parent == nil && c.cfg.completion {
+		cmd.EnableShellCompletion = true
+		cmd.ConfigureShellCompletionCommand = func(completion *cli.Command) {
+			completion.Hidden = false
+			// write the script to the root's writer, like the rest of the output
+			action := completion.Action
+			completion.Action = func(ctx context.Context, cmd *cli.Command) error {
+				cmd.Writer = cmd.Root().Writer
+				return action(ctx, cmd)
+			}
+		}
+	}
+
+	if !c.pending {
+		if err := c.setupUrfave(cmd); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
+}
+
+// loadUrfave binds the pending subcommand about to run, before urfave/cli parses its flags.
+// It is the SuggestCommandFunc of commands with subcommands left pending by WithLazy,
+// which urfave/cli calls with the name of the subcommand right before looking it up.
+func (c *node) loadUrfave(commands []*cli.Command, name string) string {
+	s := c.subcommand(name)
+	if s == nil || !s.pending {
+		return name
+	}
+	for _, cmd := range commands {
+		if cmd.Name != s.name {
+			continue
+		}
+		err := s.load()
+		if err == nil {
+			err = s.setupUrfave(cmd)
+		}
+		if err != nil {
+			cmd.SkipFlagParsing = true
+			cmd.Action = func(context.Context, *cli.Command) error {
+				return err
+			}
+		}
+	}
+	return name
+}
+
+// setupUrfave adds the flags of c to cmd and wires up running it.
+// cmd may already have flags, such as the help flag urfave/cli adds to pending commands.
+func (c *node) setupUrfave(cmd *cli.Command) error {
+	flags, err := c.toUrfaveFlags()
+	if err != nil {
+		return err
+	}
+	cmd.Flags = append(cmd.Flags, flags...)
+	if c.globals != nil {
+		// flags are inherited by subcommands in urfave/cli v3
+		globals, err := c.globals.toUrfaveFlags()
+		if err != nil {
+			return err
+		}
+		cmd.Flags = append(cmd.Flags, globals...)
 	}
+	if c.parent == nil && c.cfg.configDefaults {
+		cmd.Flags = append(cmd.Flags, &cli.StringFlag{
+			Name:        configFlag,
+			Usage:       "config file to load",
+			Destination: &c.cfg.configPath,
+		})
+	}
+	cmd.ShellComplete = c.urfaveShellComplete()
 
 	// Set action
 	if c.run != nil {
@@ -33,287 +114,289 @@ func (c *node) toUrfaveCommand() *cli.Command {
 			Run(ctx context.Context, cmd *cli.Command) error
 		}
 
+		var run cli.ActionFunc
 		if urfaveCmd, ok := c.target.(urfaveCommandV3); ok {
+			// Call the UrfaveCommand's Run method directly
+			run = urfaveCmd.Run
+		} else {
+			run = func(ctx context.Context, cliCmd *cli.Command) error {
+				return c.run(ctx, cliCmd.Args().Slice())
+			}
+		}
+
+		prepare := func(ctx context.Context, cliCmd *cli.Command) (context.Context, error) {
+			// Parse flags from command into struct fields
+			if err := c.parseUrfaveFlags(cliCmd); err != nil {
+				return ctx, err
+			}
+			if err := c.parseUrfaveGlobals(cliCmd); err != nil {
+				return ctx, err
+			}
+			c.warnDeprecated(cliCmd.Root().ErrWriter, cliCmd.IsSet)
+			return c.prepare(ctx, cliCmd.Args().Slice(), cliCmd.IsSet)
+		}
+
+		if c.isGroup {
 			cmd.Action = func(ctx context.Context, cliCmd *cli.Command) error {
-				// Parse flags from command into struct fields
-				if err := c.parseUrfaveFlags(cliCmd); err != nil {
-					return err
-				}
-				// Parse positional arguments
-				args := cliCmd.Args().Slice()
-				if err := c.parsePositionalArgs(args); err != nil {
-					return err
-				}
-				// Validate options if command doesn't implement Validator
-				if err := c.validateOptions(); err != nil {
+				ctx, err := prepare(ctx, cliCmd)
+				if err != nil {
 					return err
 				}
-				// Call the UrfaveCommand's Run method directly
-				return urfaveCmd.Run(ctx, cliCmd)
+				return run(ctx, cliCmd)
 			}
 		} else {
-			// Use the standard run function for other command types
-			originalRun := c.run
+			// urfave/cli runs the Before of every command in the chain, so a root leaf would run
+			// its hooks for the completion and help subcommands too. They are run from Action
+			// instead, and the error from run is held back so the After hooks can see (and replace) it.
+			var ran bool
+			var runCtx context.Context
+			var runErr error
 			cmd.Action = func(ctx context.Context, cliCmd *cli.Command) error {
-				// Parse flags from command into struct fields
-				if err := c.parseUrfaveFlags(cliCmd); err != nil {
+				ran = false
+				ctx, err := prepare(ctx, cliCmd)
+				if err != nil {
 					return err
 				}
-				// Parse positional arguments
-				args := cliCmd.Args().Slice()
-				if err := c.parsePositionalArgs(args); err != nil {
+				if err := c.before(ctx); err != nil {
 					return err
 				}
-				// Validate options if command doesn't implement Validator
-				if err := c.validateOptions(); err != nil {
-					return err
-				}
-				// Call the original run function with nil cobra command since we're in urfave context
-				originalRun(nil, args)
+				ran, runCtx = true, ctx
+				runErr = run(ctx, cliCmd)
 				return nil
 			}
+			cmd.After = func(ctx context.Context, cliCmd *cli.Command) error {
+				if !ran {
+					return nil
+				}
+				return c.after(runCtx, runErr)
+			}
 		}
 	}
-
-	return cmd
+	return nil
 }
 
 // toUrfaveFlags converts the node's options to urfave/cli flags
-func (c *node) toUrfaveFlags() []cli.Flag {
+func (c *node) toUrfaveFlags() ([]cli.Flag, error) {
 	var flags []cli.Flag
-	for _, o := range c.options {
-		if flag := o.toUrfaveFlag(); flag != nil {
+	for i := range c.options {
+		flag, err := c.options[i].toUrfaveFlag()
+		if err != nil {
+			return nil, err
+		}
+		if flag != nil {
 			flags = append(flags, flag)
 		}
 	}
-	return flags
+	return flags, nil
 }
 
-// toUrfaveFlag converts an option to a urfave/cli flag
-func (o *option) toUrfaveFlag() cli.Flag {
+// toUrfaveFlag converts an option to a urfave/cli flag.
+// The field's current value, which already has the defaults applied, is the flag's default.
+func (o *option) toUrfaveFlag() (cli.Flag, error) {
 	if o.Ignore {
-		return nil
+		return nil, nil
 	}
 
-	name := o.Name
-	usage := o.Help
-
-	// Build aliases (short flags)
-	var aliases []string
-	if o.Short != "" {
-		aliases = append(aliases, o.Short)
+	v := o.Target
+	if v.Type() == reflect.TypeOf(time.Time{}) && o.Layout == "" {
+		return urfaveFlag(o, &cli.TimestampFlag{
+			Config: cli.TimestampConfig{Layouts: []string{time.RFC3339}},
+		}), nil
 	}
 
-	v := o.Target
+	// Types that parse themselves, or slices of them, write directly to the field,
+	// and options with choices, layouts or schemes check them as they are parsed
+	if usesCustomValue(v) || o.parsedByOption() {
+		return urfaveFlag(o, &cli.GenericFlag{}), nil
+	}
 
-	// Handle slice types (automatically repeated)
-	if v.Kind() == reflect.Slice {
-		elemType := v.Type().Elem()
-		switch elemType.Kind() {
-		case reflect.String:
-			return &cli.StringSliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		case reflect.Int:
-			return &cli.IntSliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		case reflect.Int64:
-			return &cli.Int64SliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		case reflect.Uint:
-			return &cli.UintSliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		case reflect.Uint64:
-			return &cli.Uint64SliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		case reflect.Float64:
-			return &cli.Float64SliceFlag{
-				Name:    name,
-				Aliases: aliases,
-				Usage:   usage,
-			}
-		default:
-			panic(fmt.Sprintf("Unable to handle slice type for urfave flag: %v", elemType.Kind()))
+	// maps parse like the other backends
+	if isMap(v) {
+		return urfaveFlag(o, &cli.GenericFlag{}), nil
+	}
+	if isSlice(v) {
+		switch v.Type().Elem() {
+		case reflect.TypeOf(""):
+			return urfaveFlag(o, &cli.StringSliceFlag{}), nil
+		case reflect.TypeOf(0):
+			return urfaveFlag(o, &cli.IntSliceFlag{}), nil
+		case reflect.TypeOf(int8(0)):
+			return urfaveFlag(o, &cli.Int8SliceFlag{}), nil
+		case reflect.TypeOf(int16(0)):
+			return urfaveFlag(o, &cli.Int16SliceFlag{}), nil
+		case reflect.TypeOf(int32(0)):
+			return urfaveFlag(o, &cli.Int32SliceFlag{}), nil
+		case reflect.TypeOf(int64(0)):
+			return urfaveFlag(o, &cli.Int64SliceFlag{}), nil
+		case reflect.TypeOf(uint(0)):
+			return urfaveFlag(o, &cli.UintSliceFlag{}), nil
+		case reflect.TypeOf(uint8(0)):
+			return urfaveFlag(o, &cli.Uint8SliceFlag{}), nil
+		case reflect.TypeOf(uint16(0)):
+			return urfaveFlag(o, &cli.Uint16SliceFlag{}), nil
+		case reflect.TypeOf(uint32(0)):
+			return urfaveFlag(o, &cli.Uint32SliceFlag{}), nil
+		case reflect.TypeOf(uint64(0)):
+			return urfaveFlag(o, &cli.Uint64SliceFlag{}), nil
+		case reflect.TypeOf(float32(0)):
+			return urfaveFlag(o, &cli.Float32SliceFlag{}), nil
+		case reflect.TypeOf(float64(0)):
+			return urfaveFlag(o, &cli.Float64SliceFlag{}), nil
+		}
+		// urfave has no flag for slices of bools, durations or named types, so they parse like the other backends
+		if isBasicKind(v.Type().Elem().Kind()) {
+			return urfaveFlag(o, &cli.GenericFlag{}), nil
 		}
+		return nil, fmt.Errorf("%w: unsupported slice type %v for flag %s", ErrInvalidType, v.Type(), o.Name)
 	}
 
-	// Handle non-slice types
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return urfaveFlag(o, &cli.DurationFlag{}), nil
+	}
+	if o.Counter {
+		// flagValue is a bool flag to urfave/cli, so -vvv and --verbose count up, and --verbose=3 sets the count
+		return urfaveCounter{urfaveFlag(o, &cli.GenericFlag{}).(*cli.GenericFlag)}, nil
+	}
 	switch v.Kind() {
 	case reflect.Bool:
-		boolVal := o.Default == "true"
-		return &cli.BoolFlag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   boolVal,
+		if o.negatable() {
+			return &cli.BoolWithInverseFlag{
+				Name:    o.Name,
+				Usage:   o.usage(),
+				Hidden:  !o.inHelp(),
+				Aliases: o.urfaveAliases(),
+				Value:   v.Bool(),
+			}, nil
 		}
+		return urfaveFlag(o, &cli.BoolFlag{}), nil
 	case reflect.Int:
-		intVal := 0
-		if o.Default != "" {
-			fmt.Sscanf(o.Default, "%d", &intVal)
-		}
-		return &cli.IntFlag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   intVal,
-		}
+		return urfaveFlag(o, &cli.IntFlag{}), nil
+	case reflect.Int8:
+		return urfaveFlag(o, &cli.Int8Flag{}), nil
+	case reflect.Int16:
+		return urfaveFlag(o, &cli.Int16Flag{}), nil
+	case reflect.Int32:
+		return urfaveFlag(o, &cli.Int32Flag{}), nil
 	case reflect.Int64:
-		var intVal int64 = 0
-		if o.Default != "" {
-			fmt.Sscanf(o.Default, "%d", &intVal)
-		}
-		return &cli.Int64Flag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   intVal,
-		}
+		return urfaveFlag(o, &cli.Int64Flag{}), nil
 	case reflect.Uint:
-		var uintVal uint = 0
-		if o.Default != "" {
-			fmt.Sscanf(o.Default, "%d", &uintVal)
-		}
-		return &cli.UintFlag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   uintVal,
-		}
+		return urfaveFlag(o, &cli.UintFlag{}), nil
+	case reflect.Uint8:
+		return urfaveFlag(o, &cli.Uint8Flag{}), nil
+	case reflect.Uint16:
+		return urfaveFlag(o, &cli.Uint16Flag{}), nil
+	case reflect.Uint32:
+		return urfaveFlag(o, &cli.Uint32Flag{}), nil
 	case reflect.Uint64:
-		var uintVal uint64 = 0
-		if o.Default != "" {
-			fmt.Sscanf(o.Default, "%d", &uintVal)
-		}
-		return &cli.Uint64Flag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   uintVal,
-		}
+		return urfaveFlag(o, &cli.Uint64Flag{}), nil
+	case reflect.Float32:
+		return urfaveFlag(o, &cli.Float32Flag{}), nil
 	case reflect.Float64:
-		var floatVal float64 = 0
-		if o.Default != "" {
-			fmt.Sscanf(o.Default, "%f", &floatVal)
-		}
-		return &cli.Float64Flag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   floatVal,
-		}
+		return urfaveFlag(o, &cli.Float64Flag{}), nil
 	case reflect.String:
-		return &cli.StringFlag{
-			Name:    name,
-			Aliases: aliases,
-			Usage:   usage,
-			Value:   o.Default,
+		return urfaveFlag(o, &cli.StringFlag{}), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported type %v for flag %s", ErrInvalidType, v.Type(), o.Name)
+}
+
+// urfaveCounter is the flag of a counter. The count can be given, but doesn't have to be,
+// so help shows the flag without a value like cobra does.
+type urfaveCounter struct {
+	*cli.GenericFlag
+}
+
+func (urfaveCounter) TakesValue() bool {
+	return false
+}
+
+func (c urfaveCounter) String() string {
+	return cli.FlagStringer(c)
+}
+
+// urfaveFlag fills in the name, help and default of f from o.
+// Generic flags get a value that writes to the field directly.
+func urfaveFlag[T any, C any, VC cli.ValueCreator[T, C]](o *option, f *cli.FlagBase[T, C, VC]) cli.Flag {
+	f.Name = o.Name
+	f.Usage = o.usage()
+	f.Hidden = !o.inHelp()
+	f.Aliases = o.urfaveAliases()
+	switch value := any(&f.Value).(type) {
+	case *cli.Value:
+		if usesCustomValue(o.Target) && !o.parsedByOption() {
+			*value = newCustomValue(o.Target)
+		} else {
+			*value = &flagValue{o: o}
 		}
 	default:
-		panic(fmt.Sprintf("Unable to handle type for urfave flag: %v", v.Kind()))
+		f.Value = o.Target.Convert(reflect.TypeFor[T]()).Interface().(T)
+	}
+	return f
+}
+
+// urfaveAliases are the short name and aliases of the option, which urfave/cli treats alike.
+func (o *option) urfaveAliases() []string {
+	var aliases []string
+	if o.Short != "" {
+		aliases = append(aliases, o.Short)
 	}
+	return append(aliases, o.Aliases...)
 }
 
 // parseUrfaveFlags reads flag values from the cli.Command and assigns them to the struct fields
 func (c *node) parseUrfaveFlags(cmd *cli.Command) error {
 	for _, opt := range c.options {
-		if opt.Ignore {
+		// counters are written to by their flagValue
+		if opt.Ignore || opt.Counter {
 			continue
 		}
-
 		v := opt.Target
-		name := opt.Name
-
-		// Handle slice types
-		if v.Kind() == reflect.Slice {
-			elemType := v.Type().Elem()
-			switch elemType.Kind() {
-			case reflect.String:
-				values := cmd.StringSlice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			case reflect.Int:
-				values := cmd.IntSlice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			case reflect.Int64:
-				values := cmd.Int64Slice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			case reflect.Uint:
-				values := cmd.UintSlice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			case reflect.Uint64:
-				values := cmd.Uint64Slice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			case reflect.Float64:
-				values := cmd.Float64Slice(name)
-				if values != nil {
-					v.Set(reflect.ValueOf(values))
-				}
-			default:
-				return fmt.Errorf("unsupported slice type for flag %s: %v", name, elemType.Kind())
-			}
+		value := reflect.ValueOf(cmd.Value(opt.Name))
+		if !value.IsValid() {
+			continue
+		}
+		// unset slice flags are empty rather than nil
+		if value.Kind() == reflect.Slice && value.Len() == 0 && v.Len() == 0 {
 			continue
 		}
+		if !value.Type().ConvertibleTo(v.Type()) {
+			return fmt.Errorf("unsupported type for flag %s: %v", opt.Name, v.Type())
+		}
+		v.Set(value.Convert(v.Type()))
+	}
+	return nil
+}
 
-		// Handle non-slice types
-		switch v.Kind() {
-		case reflect.Bool:
-			v.SetBool(cmd.Bool(name))
-		case reflect.Int:
-			v.SetInt(int64(cmd.Int(name)))
-		case reflect.Int64:
-			v.SetInt(cmd.Int64(name))
-		case reflect.Uint:
-			v.SetUint(uint64(cmd.Uint(name)))
-		case reflect.Uint64:
-			v.SetUint(cmd.Uint64(name))
-		case reflect.Float64:
-			v.SetFloat(cmd.Float64(name))
-		case reflect.String:
-			v.SetString(cmd.String(name))
-		default:
-			return fmt.Errorf("unsupported type for flag %s: %v", name, v.Kind())
+// parseUrfaveGlobals reads the globals of every group above c from the running command.
+func (c *node) parseUrfaveGlobals(cmd *cli.Command) error {
+	for _, n := range c.lineage() {
+		if n.globals == nil {
+			continue
+		}
+		if err := n.globals.parseUrfaveFlags(cmd); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 // BindUrfave binds a structure to a *cli.Command (and sub-commands)
-func BindUrfave(name string, root any) (*cli.Command, error) {
-	rn := new(node)
-	err := rn.fromStruct(name, root)
+func BindUrfave(name string, root any, opts ...BindOption) (*cli.Command, error) {
+	rn, err := bind(name, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := rn.toUrfaveCommand()
 	if err != nil {
 		return nil, err
 	}
-	return rn.toUrfaveCommand(), nil
+	// bundled short flags, like -vvv for a counter, as cobra and Run take them
+	cmd.UseShortOptionHandling = true
+	return cmd, nil
 }
 
 // MustBindUrfave will panic if BindUrfave returns an error
-func MustBindUrfave(name string, root any) *cli.Command {
-	cmd, err := BindUrfave(name, root)
+func MustBindUrfave(name string, root any, opts ...BindOption) *cli.Command {
+	cmd, err := BindUrfave(name, root, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -1,14 +1,30 @@
+//go:build !quack_nourfave
+
 package quack
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v3"
 )
 
+func init() {
+	addBackend("urfave", func(opts ...BindOption) backend {
+		return func(t *testing.T, root any, args ...string) error {
+			cmd, err := BindUrfave("app", root, opts...)
+			if err != nil {
+				return err
+			}
+			return cmd.Run(context.Background(), append([]string{"app"}, args...))
+		}
+	})
+}
+
 type simpleUrfaveCmd struct {
 	NoHelp string
 	AnInt  int `help:"I am an int"`
@@ -258,3 +274,286 @@ func TestUrfaveOptionValidation(t *testing.T) {
 	})
 }
 
+func TestUrfaveErrorCommands(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	t.Run("run_e_success", func(t *testing.T) {
+		cmd := new(errCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		err = app.Run(ctx, []string{"test"})
+		assert.Nil(t, err)
+		assert.Equal(t, "value", cmd.ctx.Value(ctxKey{}))
+	})
+
+	t.Run("run_e_failure", func(t *testing.T) {
+		cmd := new(errCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		err = app.Run(ctx, []string{"test", "--fail"})
+		assert.EqualError(t, err, "run failed")
+	})
+
+	t.Run("run_context_failure", func(t *testing.T) {
+		cmd := new(errSimpleCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		err = app.Run(ctx, []string{"test", "--fail"})
+		assert.EqualError(t, err, "run failed")
+		assert.Equal(t, "value", cmd.ctx.Value(ctxKey{}))
+	})
+}
+
+func TestUrfaveDefaulter(t *testing.T) {
+	t.Run("defaults_applied", func(t *testing.T) {
+		cmd := new(defaulterCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test"}))
+		assert.True(t, cmd.Touched)
+		assert.Equal(t, "localhost", cmd.Host)
+		assert.Equal(t, 8080, cmd.Port)
+		assert.Equal(t, []string{"a", "b"}, cmd.Tags)
+		assert.Equal(t, defaultedOption("from-type"), cmd.Level)
+		assert.Equal(t, defaultedOption("from-tag"), cmd.Tagged)
+		assert.Equal(t, "here", cmd.Target)
+	})
+
+	t.Run("user_input_wins", func(t *testing.T) {
+		cmd := new(defaulterCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		err = app.Run(context.Background(), []string{"test", "--port", "9000", "--tags", "c", "--level", "x", "there"})
+		assert.Nil(t, err)
+		assert.Equal(t, 9000, cmd.Port)
+		assert.Equal(t, []string{"c"}, cmd.Tags)
+		assert.Equal(t, defaultedOption("x"), cmd.Level)
+		assert.Equal(t, "there", cmd.Target)
+	})
+}
+
+func TestUrfaveCustomTypes(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		cmd := new(customTypesCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		err = app.Run(context.Background(), []string{
+			"test",
+			"--version", "v2.3",
+			"--level", "high",
+			"--addr", "10.0.0.1",
+			"--versions", "v1.0", "--versions", "v1.1",
+			"v3.0", "v4.0", "v4.1",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, version{2, 3}, cmd.Version)
+		assert.Equal(t, level(2), cmd.Level)
+		assert.Equal(t, "10.0.0.1", cmd.Addr.String())
+		assert.Equal(t, []version{{1, 0}, {1, 1}}, cmd.Versions)
+		assert.Equal(t, version{3, 0}, cmd.Target)
+		assert.Equal(t, []version{{4, 0}, {4, 1}}, cmd.Rest)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		cmd := new(customTypesCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test", "v3.0", "v4.0"}))
+		assert.Equal(t, version{1, 2}, cmd.Version)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		app, err := BindUrfave("test", new(customTypesCmd))
+		assert.Nil(t, err)
+
+		err = app.Run(context.Background(), []string{"test", "--level", "medium", "v3.0", "v4.0"})
+		assert.ErrorContains(t, err, `unknown level "medium"`)
+	})
+}
+
+func TestUrfaveEnv(t *testing.T) {
+	t.Run("env_over_default", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "9000")
+		t.Setenv("TEST_HOSTS", "a,b")
+		t.Setenv("TEST_NAME", "from-env")
+		cmd := new(envCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test"}))
+		assert.Equal(t, 9000, cmd.Port)
+		assert.Equal(t, []string{"a", "b"}, cmd.Hosts)
+		assert.Equal(t, "from-env", cmd.Name)
+	})
+
+	t.Run("flag_over_env", func(t *testing.T) {
+		t.Setenv("TEST_PORT", "9000")
+		t.Setenv("TEST_NAME", "from-env")
+		cmd := new(envCmd)
+		app, err := BindUrfave("test", cmd)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test", "--port", "10", "from-args"}))
+		assert.Equal(t, 10, cmd.Port)
+		assert.Equal(t, "from-args", cmd.Name)
+	})
+
+	t.Run("auto_env", func(t *testing.T) {
+		t.Setenv("MYAPP_SERVE_LISTEN_PORT", "9000")
+		t.Setenv("CUSTOM_HOST", "example.com")
+		cmd := new(autoEnvCmd)
+		app, err := BindUrfave("myapp", Map{"serve": cmd}, WithAutoEnv())
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"myapp", "serve"}))
+		assert.Equal(t, 9000, cmd.ListenPort)
+		assert.Equal(t, "example.com", cmd.Host)
+	})
+}
+
+func TestUrfaveOptionGroups(t *testing.T) {
+	cmd := new(groupedCmd)
+	app, err := BindUrfave("test", cmd)
+	assert.Nil(t, err)
+
+	err = app.Run(context.Background(), []string{"test", "-l", "debug", "--token", "abc", "--db-host", "db", "--ro-port", "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "debug", cmd.Level)
+	assert.Equal(t, "abc", cmd.authConfig.Token)
+	assert.Equal(t, "db", cmd.DB.Host)
+	assert.Equal(t, 5432, cmd.DB.Port)
+	assert.Equal(t, "localhost", cmd.Replica.Host)
+	assert.Equal(t, 1, cmd.Replica.Port)
+}
+
+func TestUrfaveGlobals(t *testing.T) {
+	t.Run("injection", func(t *testing.T) {
+		root := newGlobalsRoot()
+		app, err := BindUrfave("test", root)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test", "-v", "leaf", "--name", "x"}))
+		assert.True(t, root.Opts.Verbose)
+		assert.Equal(t, globalOpts{Verbose: true, Endpoint: "https://example.com"}, root.leaf.Globals)
+		assert.Same(t, &root.Opts, root.leaf.Shared)
+		assert.Equal(t, "x", root.leaf.Name)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Setenv("TEST_ENDPOINT", "https://env.com")
+		root := newGlobalsRoot()
+		app, err := BindUrfave("test", root)
+		assert.Nil(t, err)
+
+		assert.Nil(t, app.Run(context.Background(), []string{"test", "mid", "ctx", "--verbose"}))
+		assert.Same(t, &root.Opts, root.ctx.globals)
+		assert.True(t, root.ctx.globals.Verbose)
+		assert.Equal(t, "https://env.com", root.ctx.globals.Endpoint)
+	})
+}
+
+func TestUrfaveDeprecated(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindUrfave("app", &renameRoot{})
+	assert.NoError(t, err)
+	cmd.ErrWriter = &out
+	assert.NoError(t, cmd.Run(context.Background(), []string{"app", "serve", "--legacy", "x"}))
+	assert.Equal(t, "Flag --legacy has been deprecated, use --port instead\n", out.String())
+}
+
+func TestUrfaveHidden(t *testing.T) {
+	cmd, err := BindUrfave("app", &renameRoot{})
+	assert.NoError(t, err)
+	var visible []string
+	for _, c := range cmd.VisibleCommands() {
+		visible = append(visible, c.Name)
+	}
+	assert.Equal(t, []string{"serve"}, visible)
+}
+
+func TestUrfaveLazyHelp(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindUrfave("app", &lazyRoot{}, WithLazy())
+	assert.NoError(t, err)
+	cmd.Writer = &out
+	assert.NoError(t, cmd.Run(context.Background(), []string{"app", "serve", "--help"}))
+	assert.Contains(t, out.String(), "--port")
+}
+
+func TestUrfaveCategories(t *testing.T) {
+	var out bytes.Buffer
+	cmd, err := BindUrfave("app", &orderedRoot{})
+	assert.NoError(t, err)
+	cmd.Writer = &out
+	assert.NoError(t, cmd.Run(context.Background(), []string{"app", "--help"}))
+
+	order, categories := helpOrder(out.String())
+	// urfave/cli sorts the categories by name, but keeps the order of their commands
+	assert.Equal(t, []string{"version", "migrate", "backup", "stop", "start"}, order)
+	assert.Equal(t, []string{"Database", "Maintenance", "Server"}, categories)
+}
+
+type unsupportedTypeCmd struct {
+	Complex complex128
+}
+
+func (u *unsupportedTypeCmd) Run([]string) {
+}
+
+func TestUrfaveUnsupportedType(t *testing.T) {
+	cmd, err := BindUrfave("app", new(unsupportedTypeCmd))
+	assert.ErrorIs(t, err, ErrInvalidType)
+	assert.ErrorContains(t, err, "complex128")
+	assert.Nil(t, cmd)
+}
+
+func urfaveComplete(t *testing.T, args ...string) []string {
+	cmd, err := BindUrfave("app", &completionRoot{}, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.Writer = out
+	assert.Nil(t, cmd.Run(context.Background(), append(append([]string{"app"}, args...), "--generate-shell-completion")))
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func TestUrfaveCompleteArg(t *testing.T) {
+	assert.Subset(t, urfaveComplete(t, "deploy"), []string{"api", "web"})
+}
+
+func TestUrfaveCompleteFlags(t *testing.T) {
+	assert.Contains(t, urfaveComplete(t, "deploy", "-"), "--region")
+}
+
+func TestUrfaveCompletionCmd(t *testing.T) {
+	cmd, err := BindUrfave("app", &completionRoot{}, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.Writer = out
+	assert.Nil(t, cmd.Run(context.Background(), []string{"app", "completion", "bash"}))
+	assert.Contains(t, out.String(), "app")
+}
+
+func TestUrfaveCompleteFlag(t *testing.T) {
+	assert.Equal(t, []string{"us-east", "us-west", "eu-central"}, urfaveComplete(t, "deploy", "--region"))
+	assert.Equal(t, []string{"us-east", "us-west", "eu-central"}, urfaveComplete(t, "deploy", "--zone"))
+	// the arguments come from the command, not from the command line of the test binary
+	assert.Subset(t, urfaveComplete(t, "deploy", "--region", "eu-central"), []string{"api", "web"})
+}
+
+func TestUrfaveCompletionCmdRootLeaf(t *testing.T) {
+	leaf := &completionLeaf{}
+	cmd, err := BindUrfave("app", leaf, WithCompletion())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cmd.Writer = out
+	assert.Nil(t, cmd.Run(context.Background(), []string{"app", "completion", "bash"}))
+	assert.Contains(t, out.String(), "app")
+	assert.Zero(t, leaf.before)
+}
@@ -0,0 +1,59 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/eliothedeman/quack/internal/fuzzy"
+)
+
+var choicerType = reflect.TypeFor[Choicer]()
+
+// choicesOf returns the choices of a field's type, or of its elements for slices.
+func choicesOf(t reflect.Type) []string {
+	if t.Kind() == reflect.Slice && !isCustom(t) {
+		t = t.Elem()
+	}
+	if !reflect.PointerTo(t).Implements(choicerType) {
+		return nil
+	}
+	return reflect.New(t).Interface().(Choicer).Choices()
+}
+
+// checkChoice reports an error if the option takes one of a set of values and value isn't one of them.
+func (o *option) checkChoice(value string) error {
+	if len(o.Choices) == 0 || slices.Contains(o.Choices, value) {
+		return nil
+	}
+	return fmt.Errorf("%q is not one of %s%s", value, strings.Join(o.Choices, ", "), suggestChoice(value, o.Choices))
+}
+
+// suggestChoice returns a hint naming the choice closest to value, if one is close enough to be a typo.
+func suggestChoice(value string, choices []string) string {
+	best, dist := "", 3
+	for _, choice := range choices {
+		if d := fuzzy.Distance(strings.ToLower(value), strings.ToLower(choice)); d < dist {
+			best, dist = choice, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// choiceCompleter completes the values of an option from its choices.
+type choiceCompleter []string
+
+func (c choiceCompleter) Complete(_ context.Context, partial string) []string {
+	var matches []string
+	for _, choice := range c {
+		if strings.HasPrefix(choice, partial) {
+			matches = append(matches, choice)
+		}
+	}
+	return matches
+}
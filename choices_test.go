@@ -0,0 +1,59 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logLevel string
+
+func (logLevel) Choices() []string { return []string{"debug", "info", "warn"} }
+
+type exportCmd struct {
+	Format string     `enum:"json,yaml,table" default:"table" help:"output format"`
+	Levels []logLevel `short:"l"`
+	Target string     `arg:"1" enum:"stdout,file"`
+}
+
+func (e *exportCmd) Run([]string) {}
+
+func TestChoices(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &exportCmd{}
+			assert.NoError(t, run(t, cmd, "--format", "yaml", "-l", "debug", "--levels", "warn", "file"))
+			assert.Equal(t, "yaml", cmd.Format)
+			assert.Equal(t, []logLevel{"debug", "warn"}, cmd.Levels)
+			assert.Equal(t, "file", cmd.Target)
+
+			err := run(t, &exportCmd{}, "--format", "jsn", "file")
+			assert.ErrorContains(t, err, `"jsn" is not one of json, yaml, table (did you mean "json"?)`)
+			err = run(t, &exportCmd{}, "-l", "error", "file")
+			assert.ErrorContains(t, err, `"error" is not one of debug, info, warn`)
+			err = run(t, &exportCmd{}, "--format", "json", "socket")
+			assert.ErrorContains(t, err, `"socket" is not one of stdout, file`)
+		})
+	}
+}
+
+func TestChoicesInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Format string `enum:"json,yaml" default:"xml"`
+		Count  int    `enum:"1,2"`
+	}{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, `invalid default value "xml" for format: "xml" is not one of json, yaml`)
+	assert.ErrorContains(t, err, "choices of count need a string or a slice of strings, got int")
+}
+
+func TestChoicesHelp(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &exportCmd{}, []string{"--help"}, WithOutput(&out)))
+	assert.Contains(t, out.String(), "output format (one of json, yaml, table)")
+	assert.Contains(t, out.String(), "(one of debug, info, warn)")
+	assert.Contains(t, out.String(), "(one of stdout, file)")
+}
@@ -0,0 +1,44 @@
+// Command quackgen generates typed field pointers and validation for quack commands, see package quackgen.
+//
+// Add a directive next to the commands, and run go generate:
+//
+//	//go:generate go run github.com/eliothedeman/quack/cmd/quackgen -type=rootCmd,serveCmd
+//
+// The bindings are written to <first type>_quack.go in the same package, unless -output is set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eliothedeman/quack/quackgen"
+	"github.com/iancoleman/strcase"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of command types")
+	output := flag.String("output", "", "output file name")
+	flag.Parse()
+
+	if *typeNames == "" {
+		fmt.Fprintln(os.Stderr, "quackgen: -type is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = strcase.ToSnake(names[0]) + "_quack.go"
+	}
+
+	src, err := quackgen.Generate(".", names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quackgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "quackgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,16 @@
+// Command quackvet checks the structs bound by quack for invalid tags and command signatures.
+//
+// Run it on its own, or alongside the standard checks with go vet:
+//
+//	go install github.com/eliothedeman/quack/cmd/quackvet
+//	go vet -vettool=$(which quackvet) ./...
+package main
+
+import (
+	"github.com/eliothedeman/quack/quackvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(quackvet.Analyzer)
+}
@@ -0,0 +1,74 @@
+package quack
+
+import (
+	"context"
+	"reflect"
+)
+
+// completer returns the Completer of the option's type, or of its elements for slices.
+// Options limited to a set of choices complete them otherwise.
+func (o *option) completer() Completer {
+	if o.Ignore || !o.Target.CanAddr() {
+		return nil
+	}
+	if o.Base != "" {
+		// paths within a base directory are completed from it
+		return pathCompleter{base: o.Base}
+	}
+	if c, ok := o.Target.Addr().Interface().(Completer); ok {
+		return c
+	}
+	if isSlice(o.Target) {
+		if c, ok := reflect.New(o.Target.Type().Elem()).Interface().(Completer); ok {
+			return c
+		}
+	}
+	if len(o.Choices) > 0 {
+		return choiceCompleter(o.Choices)
+	}
+	return nil
+}
+
+// argCompleter returns the Completer for the positional argument at index, and whether c takes one there.
+// Commands implementing Completer are used when the argument's type doesn't.
+func (c *node) argCompleter(index int) (Completer, bool) {
+	positional := false
+	for _, o := range c.positionalOptions {
+		if index == o.Arg-1 || (isRepeated(o.Target) && index >= o.Arg-1) {
+			if comp := o.completer(); comp != nil {
+				return comp, true
+			}
+			positional = !o.Ignore
+			break
+		}
+	}
+	if comp, ok := c.target.(Completer); ok {
+		return comp, true
+	}
+	return nil, positional
+}
+
+// completeArg suggests values for the positional argument at index, given the args before it.
+func (c *node) completeArg(ctx context.Context, index int, partial string) []string {
+	if comp, _ := c.argCompleter(index); comp != nil {
+		return comp.Complete(ctx, partial)
+	}
+	return nil
+}
+
+// lookupOption finds a flag of c by long or short name.
+func (c *node) lookupOption(name string) *option {
+	for _, o := range c.flagOptions() {
+		if o.hasName(name) || (o.Short != "" && o.Short == name) {
+			return o
+		}
+	}
+	return nil
+}
+
+func completionContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
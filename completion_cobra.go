@@ -0,0 +1,64 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// addCobraCompletions registers completion functions for positional args and options with a Completer.
+// Positional args without one are left to the shell, which completes file names.
+func (c *node) addCobraCompletions(cmd *cobra.Command) {
+	cmd.ValidArgsFunction = func(cobraCmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		comp, positional := c.argCompleter(len(args))
+		if comp == nil {
+			if positional {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return comp.Complete(completionContext(cobraCmd.Context()), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	// globals are persistent flags of c, and cobra finds their completions from any subcommand
+	options := c.options
+	if c.globals != nil {
+		options = append(slices.Clip(options), c.globals.options...)
+	}
+	for _, o := range options {
+		comp := o.completer()
+		if comp == nil {
+			continue
+		}
+		cmd.RegisterFlagCompletionFunc(o.Name, func(cobraCmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return comp.Complete(completionContext(cobraCmd.Context()), toComplete), cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+}
+
+// cobraCompletionCmd prints the completion script of root for the shell passed as its argument.
+func cobraCompletionCmd(root *cobra.Command) *cobra.Command {
+	return &cobra.Command{
+		Use:                   "completion [bash|zsh|fish|powershell]",
+		Short:                 "Output shell completion script for bash, zsh, fish, or powershell",
+		DisableFlagsInUseLine: true,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+			return fmt.Errorf("unsupported shell %s", args[0])
+		},
+	}
+}
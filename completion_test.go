@@ -0,0 +1,62 @@
+package quack
+
+import (
+	"context"
+	"strings"
+)
+
+type region string
+
+func (r region) Complete(ctx context.Context, partial string) []string {
+	var out []string
+	for _, s := range []string{"us-east", "us-west", "eu-central"} {
+		if strings.HasPrefix(s, partial) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+type deployCmd struct {
+	Region  region           `help:"region to deploy to"`
+	Service string           `arg:"1"`
+	Script  ExistingFilePath `help:"deploy script"`
+}
+
+func (d *deployCmd) Run(args []string) {}
+
+func (d *deployCmd) Complete(ctx context.Context, partial string) []string {
+	return []string{"api", "web"}
+}
+
+type copyCmd struct {
+	Source string `arg:"1"`
+}
+
+func (c *copyCmd) Run(args []string) {}
+
+type completionGlobals struct {
+	Zone region
+}
+
+type completionRoot struct {
+	g completionGlobals
+}
+
+func (c *completionRoot) Globals() any { return &c.g }
+
+func (c *completionRoot) SubCommands() Map {
+	return Map{"deploy": &deployCmd{}, "copy": &copyCmd{}}
+}
+
+type completionLeaf struct {
+	Name   string `required:""`
+	before int
+}
+
+func (c *completionLeaf) Run([]string) {}
+
+func (c *completionLeaf) Before(context.Context) error {
+	c.before++
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build !quack_nourfave
+
+package quack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// urfaveShellComplete suggests values for options and positional args with a Completer,
+// falling back to urfave's flag and subcommand suggestions.
+func (c *node) urfaveShellComplete() cli.ShellCompleteFunc {
+	return func(ctx context.Context, cmd *cli.Command) {
+		// urfave fails to parse a flag missing its value, so look at the raw args of the command,
+		// which its parent keeps after the name of the command. The root only has its positional args.
+		args := cmd.Args().Slice()
+		if lineage := cmd.Lineage(); len(lineage) > 1 {
+			args = lineage[1].Args().Tail()
+		}
+		if len(args) > 0 {
+			last := args[len(args)-1]
+			if strings.HasPrefix(last, "-") && last != "-" && last != "--" {
+				if o := c.lookupOption(strings.TrimLeft(last, "-")); o != nil && o.completer() != nil {
+					for _, s := range o.completer().Complete(ctx, "") {
+						fmt.Fprintln(cmd.Root().Writer, s)
+					}
+					return
+				}
+			}
+		}
+		for _, s := range c.completeArg(ctx, cmd.Args().Len(), "") {
+			fmt.Fprintln(cmd.Root().Writer, s)
+		}
+		cli.DefaultCompleteWithFlags(ctx, cmd)
+	}
+}
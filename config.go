@@ -0,0 +1,264 @@
+package quack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configFlag is the name of the flag used to pass an explicit config file.
+const configFlag = "config"
+
+// configDecoders decode the supported config file formats by extension.
+// YAML and TOML are registered by config_yaml.go and config_toml.go, see the quack_noyaml and quack_notoml build tags.
+var configDecoders = map[string]func(data []byte, v any) error{
+	".json": json.Unmarshal,
+}
+
+// configExtensions lists the supported config file formats, in the order they are searched for.
+func configExtensions() []string {
+	var exts []string
+	for _, ext := range []string{".json", ".yaml", ".yml", ".toml"} {
+		if configDecoders[ext] != nil {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+func decodeConfig(path string, data []byte) (map[string]any, error) {
+	decode, ok := configDecoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format %s: must be one of %s", path, strings.Join(configExtensions(), ", "))
+	}
+	out := map[string]any{}
+	if err := decode(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// mergeConfig deep merges src into dst, values in src take precedence.
+func mergeConfig(dst, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				mergeConfig(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// configPaths lists every config file that may be loaded for the command name, lowest precedence first.
+func (b *bindConfig) configPaths(name string) []string {
+	var paths []string
+	if b.configDefaults {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				dir = filepath.Join(home, ".config")
+			}
+		}
+		exts := configExtensions()
+		if dir != "" {
+			for _, ext := range exts {
+				paths = append(paths, filepath.Join(dir, name, "config"+ext))
+			}
+		}
+		for _, ext := range exts {
+			paths = append(paths, "."+name+ext)
+		}
+	}
+	return append(paths, b.configFiles...)
+}
+
+// loadConfig reads and merges all config files for the command name.
+// Files in default locations are skipped if they don't exist, the file passed with --config must exist.
+func (b *bindConfig) loadConfig(name string) (map[string]any, error) {
+	conf := map[string]any{}
+	load := func(path string, optional bool) error {
+		data, err := os.ReadFile(path)
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		layer, err := decodeConfig(path, data)
+		if err != nil {
+			return err
+		}
+		mergeConfig(conf, layer)
+		return nil
+	}
+
+	for _, p := range b.configPaths(name) {
+		if err := load(p, true); err != nil {
+			return nil, err
+		}
+	}
+	if b.configPath != "" {
+		if err := load(b.configPath, false); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// configKeys are the keys allowed in the config section of a command.
+func (c *node) configKeys() []string {
+	var keys []string
+	for _, o := range c.options {
+		if !o.Ignore {
+			keys = append(keys, o.Name)
+		}
+	}
+	if c.globals != nil {
+		for _, o := range c.globals.options {
+			if !o.Ignore {
+				keys = append(keys, o.Name)
+			}
+		}
+	}
+	for _, s := range c.subcommands {
+		keys = append(keys, s.name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *node) checkConfigKeys(section map[string]any) error {
+	valid := c.configKeys()
+	var unknown []string
+	for k := range section {
+		if i := sort.SearchStrings(valid, k); i == len(valid) || valid[i] != k {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf(
+		"unknown config keys %s for %q: valid keys are %s",
+		strings.Join(unknown, ", "),
+		strings.Join(c.path(), " "),
+		strings.Join(valid, ", "),
+	)
+}
+
+// configSections finds the config section for every node from the root to c,
+// checking every section on the way for unknown keys.
+// Sections missing from the config are nil.
+func (c *node) configSections(conf map[string]any) ([]map[string]any, error) {
+	lineage := c.lineage()
+	sections := make([]map[string]any, len(lineage))
+	section := conf
+	for i, n := range lineage {
+		// the keys of a group left pending by WithLazy aren't known until it is bound
+		if err := n.load(); err != nil {
+			return nil, err
+		}
+		if err := n.checkConfigKeys(section); err != nil {
+			return nil, err
+		}
+		sections[i] = section
+		if i == len(lineage)-1 {
+			break
+		}
+		next := lineage[i+1].name
+		raw, ok := section[next]
+		if !ok {
+			break
+		}
+		section, ok = raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("config key %q for %q must be a section", next, strings.Join(n.path(), " "))
+		}
+	}
+	return sections, nil
+}
+
+// applyConfig sets named options, and the globals of every group above c, from the loaded config files,
+// unless they were already provided.
+func (c *node) applyConfig() error {
+	if !c.cfg.configEnabled() {
+		return nil
+	}
+	lineage := c.lineage()
+	conf, err := c.cfg.loadConfig(lineage[0].name)
+	if err != nil {
+		return err
+	}
+	sections, err := c.configSections(conf)
+	if err != nil {
+		return err
+	}
+	for i, n := range lineage {
+		if n.globals != nil {
+			if err := applyConfigSection(n.globals.options, sections[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return applyConfigSection(c.options, sections[len(sections)-1])
+}
+
+func applyConfigSection(options []option, section map[string]any) error {
+	for i := range options {
+		o := &options[i]
+		raw, ok := section[o.Name]
+		if o.Ignore || !ok || o.provided {
+			continue
+		}
+		if err := o.setConfigValue(raw); err != nil {
+			return fmt.Errorf("failed to parse %s from config: %w", o.Name, err)
+		}
+		o.provided = true
+	}
+	return nil
+}
+
+// setConfigValue assigns a decoded config value to the target field.
+func (o *option) setConfigValue(raw any) error {
+	if isMap(o.Target) {
+		return o.setConfigMap(raw)
+	}
+	if !isSlice(o.Target) {
+		return o.parseValue(configString(raw))
+	}
+	o.Target.Set(reflect.Zero(o.Target.Type()))
+	elems, ok := raw.([]any)
+	if !ok {
+		elems = []any{raw}
+	}
+	for _, e := range elems {
+		if err := o.appendValue(configString(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configString converts a decoded config value back to the form it would have on the command line.
+func configString(raw any) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,186 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type configServeCmd struct {
+	Port  int    `default:"80" env:"CONFIG_TEST_PORT"`
+	Host  string `default:"localhost"`
+	Tags  []string
+	Debug bool
+}
+
+func (c *configServeCmd) Run([]string) {
+}
+
+type configRootCmd struct {
+	serve   *configServeCmd
+	Verbose bool
+}
+
+func (c *configRootCmd) SubCommands() Map {
+	return Map{"serve": c.serve}
+}
+
+func newConfigRoot() *configRootCmd {
+	return &configRootCmd{serve: new(configServeCmd)}
+}
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"c.json": `{"serve": {"port": 8080, "tags": ["a", "b"], "debug": true}}`,
+		"c.yaml": "serve:\n  port: 8080\n  tags: [a, b]\n  debug: true\n",
+		"c.toml": "[serve]\nport = 8080\ntags = [\"a\", \"b\"]\ndebug = true\n",
+	}
+	for name, content := range files {
+		path := writeConfig(t, dir, name, content)
+		for backend, run := range backendsWith(WithConfigFiles(path)) {
+			t.Run(name+"/"+backend, func(t *testing.T) {
+				if configDecoders[filepath.Ext(name)] == nil {
+					t.Skipf("%s is built out", filepath.Ext(name))
+				}
+				root := newConfigRoot()
+				require.Nil(t, run(t, root, "serve"))
+				assert.Equal(t, 8080, root.serve.Port)
+				assert.Equal(t, "localhost", root.serve.Host)
+				assert.Equal(t, []string{"a", "b"}, root.serve.Tags)
+				assert.True(t, root.serve.Debug)
+			})
+		}
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "c.json", `{"serve": {"port": 8080, "host": "example.com"}}`)
+	override := writeConfig(t, dir, "override.json", `{"serve": {"port": 1}}`)
+
+	for backend, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run("env_over_config/"+backend, func(t *testing.T) {
+			t.Setenv("CONFIG_TEST_PORT", "9000")
+			root := newConfigRoot()
+			require.Nil(t, run(t, root, "serve"))
+			assert.Equal(t, 9000, root.serve.Port)
+			assert.Equal(t, "example.com", root.serve.Host)
+		})
+
+		t.Run("flag_over_config/"+backend, func(t *testing.T) {
+			root := newConfigRoot()
+			require.Nil(t, run(t, root, "serve", "--host", "flag.com"))
+			assert.Equal(t, 8080, root.serve.Port)
+			assert.Equal(t, "flag.com", root.serve.Host)
+		})
+	}
+
+	for backend, run := range backendsWith(WithConfigFiles(path, override)) {
+		t.Run("layers/"+backend, func(t *testing.T) {
+			root := newConfigRoot()
+			require.Nil(t, run(t, root, "serve"))
+			assert.Equal(t, 1, root.serve.Port)
+			assert.Equal(t, "example.com", root.serve.Host)
+		})
+	}
+}
+
+func TestConfigLocations(t *testing.T) {
+	xdg := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Chdir(project)
+	writeConfig(t, xdg, "app/config.json", `{"serve": {"port": 1, "host": "xdg", "debug": true}}`)
+	writeConfig(t, project, ".app.json", `{"serve": {"port": 2, "host": "project"}}`)
+	explicit := writeConfig(t, t.TempDir(), "explicit.json", `{"serve": {"port": 3}}`)
+
+	for backend, run := range backendsWith(WithConfig()) {
+		t.Run(backend, func(t *testing.T) {
+			root := newConfigRoot()
+			require.Nil(t, run(t, root, "--config", explicit, "serve"))
+			assert.Equal(t, 3, root.serve.Port)
+			assert.Equal(t, "project", root.serve.Host)
+			assert.True(t, root.serve.Debug)
+		})
+
+		t.Run("missing_explicit/"+backend, func(t *testing.T) {
+			assert.ErrorContains(t, run(t, newConfigRoot(), "serve", "--config", filepath.Join(project, "missing.json")),
+				"failed to read config")
+		})
+	}
+}
+
+func TestConfigFlagPosition(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	explicit := writeConfig(t, t.TempDir(), "explicit.json", `{"serve": {"port": 3}}`)
+
+	for name, run := range backendsWith(WithConfig()) {
+		t.Run(name, func(t *testing.T) {
+			root := newConfigRoot()
+			assert.Nil(t, run(t, root, "--config", explicit, "serve"))
+			assert.Equal(t, 3, root.serve.Port)
+
+			root = newConfigRoot()
+			assert.Nil(t, run(t, root, "serve", "--config", explicit))
+			assert.Equal(t, 3, root.serve.Port)
+		})
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "c.json", `{"verbose": true, "serve": {"prot": 1}}`)
+
+	for backend, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run(backend, func(t *testing.T) {
+			assert.EqualError(t, run(t, newConfigRoot(), "serve"),
+				`unknown config keys prot for "app serve": valid keys are debug, host, port, tags`)
+		})
+	}
+}
+
+func TestConfigGlobals(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), "c.json", `{"endpoint": "https://config.com", "leaf": {"name": "y"}}`)
+
+	for backend, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run(backend, func(t *testing.T) {
+			root := newGlobalsRoot()
+			require.Nil(t, run(t, root, "leaf"))
+			assert.Equal(t, "https://config.com", root.Opts.Endpoint)
+			assert.Equal(t, "https://config.com", root.leaf.Globals.Endpoint)
+			assert.Equal(t, "y", root.leaf.Name)
+		})
+	}
+}
+
+func TestConfigLazy(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "c.json", `{"cloud": {"region": "eu", "deploy": {}}}`)
+	typo := writeConfig(t, dir, "typo.json", `{"cloud": {"regoin": "eu"}}`)
+	for name, run := range backendsWith(WithLazy(), WithConfigFiles(path)) {
+		t.Run(name, func(t *testing.T) {
+			root := &lazyCloudRoot{}
+			require.NoError(t, run(t, root, "cloud", "deploy"))
+			assert.Equal(t, "eu", root.cloud.g.Region)
+		})
+	}
+	for name, run := range backendsWith(WithLazy(), WithConfigFiles(typo)) {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, run(t, &lazyCloudRoot{}, "cloud", "deploy"),
+				`unknown config keys regoin for "app cloud": valid keys are deploy, region`)
+		})
+	}
+}
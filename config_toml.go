@@ -0,0 +1,9 @@
+//go:build !quack_notoml
+
+package quack
+
+import "github.com/BurntSushi/toml"
+
+func init() {
+	configDecoders[".toml"] = toml.Unmarshal
+}
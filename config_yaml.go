@@ -0,0 +1,10 @@
+//go:build !quack_noyaml
+
+package quack
+
+import "gopkg.in/yaml.v3"
+
+func init() {
+	configDecoders[".yaml"] = yaml.Unmarshal
+	configDecoders[".yml"] = yaml.Unmarshal
+}
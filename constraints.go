@@ -0,0 +1,142 @@
+package quack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// splitTag splits a comma separated tag value, ignoring empty entries.
+func splitTag(tag string) []string {
+	var out []string
+	for _, s := range strings.Split(tag, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func flagList(names []string) string {
+	flags := make([]string, len(names))
+	for i, n := range names {
+		flags[i] = "--" + n
+	}
+	return strings.Join(flags, ", ")
+}
+
+// constraintGroups collects the names of the options in every group of a relation (xor or and).
+func constraintGroups(options []*option, groupsOf func(*option) []string) (map[string][]string, []string) {
+	groups := map[string][]string{}
+	for _, o := range options {
+		for _, g := range groupsOf(o) {
+			groups[g] = append(groups[g], o.Name)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	return groups, names
+}
+
+func without(names []string, name string) []string {
+	var out []string
+	for _, n := range names {
+		if n != name {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// bindConstraints checks the constraints between the node's options refer to known options,
+// and describes them in the help of each option. Options can be constrained together with the
+// globals of the groups above them.
+func (c *node) bindConstraints() {
+	options := c.flagOptions()
+	xor, _ := constraintGroups(options, func(o *option) []string { return o.Xor })
+	and, _ := constraintGroups(options, func(o *option) []string { return o.And })
+
+	for i := range c.options {
+		o := &c.options[i]
+		if o.Required {
+			o.notes = append(o.notes, "(required)")
+		}
+		for _, g := range o.Xor {
+			o.notes = append(o.notes, fmt.Sprintf("(conflicts with %s)", flagList(without(xor[g], o.Name))))
+		}
+		for _, g := range o.And {
+			o.notes = append(o.notes, fmt.Sprintf("(requires %s)", flagList(without(and[g], o.Name))))
+		}
+		var requires []string
+		for _, r := range o.Requires {
+			target := c.lookupOption(r)
+			if target == nil {
+				c.problem(fmt.Errorf("option %s requires unknown option %s", o.Name, r))
+				continue
+			}
+			requires = append(requires, target.Name)
+		}
+		o.Requires = requires
+		if len(o.Requires) > 0 {
+			o.notes = append(o.notes, fmt.Sprintf("(requires %s)", flagList(o.Requires)))
+		}
+	}
+}
+
+// checkConstraints enforces the required, xor, and and requires tags on options that have been resolved.
+func checkConstraints(options []*option) error {
+	provided := map[string]bool{}
+	var missing []string
+	for _, o := range options {
+		provided[o.Name] = o.provided
+		if o.Required && !o.provided {
+			missing = append(missing, o.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", flagList(missing))
+	}
+
+	xor, xorNames := constraintGroups(options, func(o *option) []string { return o.Xor })
+	for _, g := range xorNames {
+		var set []string
+		for _, n := range xor[g] {
+			if provided[n] {
+				set = append(set, n)
+			}
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("flags %s are mutually exclusive", flagList(set))
+		}
+	}
+
+	and, andNames := constraintGroups(options, func(o *option) []string { return o.And })
+	for _, g := range andNames {
+		var set, unset []string
+		for _, n := range and[g] {
+			if provided[n] {
+				set = append(set, n)
+			} else {
+				unset = append(unset, n)
+			}
+		}
+		if len(set) > 0 && len(unset) > 0 {
+			return fmt.Errorf("flags %s must be used together: missing %s", flagList(and[g]), flagList(unset))
+		}
+	}
+
+	for _, o := range options {
+		if !o.provided {
+			continue
+		}
+		for _, r := range o.Requires {
+			if !provided[r] {
+				return fmt.Errorf("flag --%s requires --%s", o.Name, r)
+			}
+		}
+	}
+	return nil
+}
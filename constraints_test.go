@@ -0,0 +1,127 @@
+package quack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type constrainedCmd struct {
+	Name     string `required:""`
+	JSON     bool   `xor:"format"`
+	YAML     bool   `xor:"format"`
+	User     string `and:"auth"`
+	Password string `and:"auth" env:"TEST_PASSWORD"`
+	TLSCert  string `requires:"tls-key"`
+	TLSKey   string
+}
+
+func (c *constrainedCmd) Run([]string) {
+}
+
+type badRequiresCmd struct {
+	Cert string `requires:"nope"`
+	Key  string `requires:"cert,other"`
+}
+
+func (b *badRequiresCmd) Run([]string) {
+}
+
+func TestConstraints(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{
+			name: "valid",
+			args: []string{"--name", "x", "--json", "--user", "u", "--password", "p"},
+		},
+		{
+			name: "required",
+			args: []string{"--json"},
+			err:  "missing required flags: --name",
+		},
+		{
+			name: "xor",
+			args: []string{"--name", "x", "--json", "--yaml"},
+			err:  "flags --json, --yaml are mutually exclusive",
+		},
+		{
+			name: "and",
+			args: []string{"--name", "x", "--user", "u"},
+			err:  "flags --user, --password must be used together: missing --password",
+		},
+		{
+			name: "and_from_env",
+			env:  map[string]string{"TEST_PASSWORD": "p"},
+			args: []string{"--name", "x", "--user", "u"},
+		},
+		{
+			name: "requires",
+			args: []string{"--name", "x", "--tls-cert", "c"},
+			err:  "flag --tls-cert requires --tls-key",
+		},
+		{
+			name: "requires_satisfied",
+			args: []string{"--name", "x", "--tls-cert", "c", "--tls-key", "k"},
+		},
+	}
+	for backend, run := range backends {
+		for _, test := range tests {
+			t.Run(backend+"/"+test.name, func(t *testing.T) {
+				for k, v := range test.env {
+					t.Setenv(k, v)
+				}
+				err := run(t, new(constrainedCmd), test.args...)
+				if test.err == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, test.err)
+				}
+			})
+		}
+	}
+}
+
+func TestConstraintsUnknownOption(t *testing.T) {
+	_, err := bind("test", new(badRequiresCmd), nil)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "test: option cert requires unknown option nope")
+	assert.ErrorContains(t, err, "test: option key requires unknown option other")
+}
+
+type tlsGlobals struct {
+	TLSKey string `alias:"key"`
+	Token  string `xor:"auth"`
+}
+
+type tlsServe struct {
+	TLSCert  string `requires:"key"`
+	Password string `xor:"auth"`
+}
+
+func (s *tlsServe) Run([]string) {}
+
+type tlsRoot struct {
+	g     tlsGlobals
+	serve tlsServe
+}
+
+func (r *tlsRoot) Globals() any { return &r.g }
+
+func (r *tlsRoot) SubCommands() Map {
+	return Map{"serve": &r.serve}
+}
+
+func TestConstraintsGlobals(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, run(t, &tlsRoot{}, "serve", "--tls-cert", "c", "--tls-key", "k"))
+			assert.EqualError(t, run(t, &tlsRoot{}, "serve", "--tls-cert", "c"), "flag --tls-cert requires --tls-key")
+			assert.EqualError(t, run(t, &tlsRoot{}, "serve", "--token", "t", "--password", "p"),
+				"flags --password, --token are mutually exclusive")
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package quack
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/eliothedeman/check"
 )
@@ -20,10 +26,155 @@ func (e ExistingFilePath) Validate() error {
 	return nil
 }
 
+// Open opens the file for reading, and panics if it can't.
+//
+// Deprecated: the file can be removed after the command starts, use OpenE.
 func (e *ExistingFilePath) Open() *os.File {
 	return check.Must(os.Open(string(*e)))
 }
 
+// OpenWith opens the file with the given flags, see os.OpenFile, and panics if it can't.
+//
+// Deprecated: use OpenWithE.
 func (e *ExistingFilePath) OpenWith(flags int, mode os.FileMode) *os.File {
 	return check.Must(os.OpenFile(string(*e), flags, mode))
 }
+
+// OpenE opens the file for reading.
+func (e ExistingFilePath) OpenE() (*os.File, error) {
+	return os.Open(string(e))
+}
+
+// OpenWithE opens the file with the given flags, see os.OpenFile.
+func (e ExistingFilePath) OpenWithE(flags int, mode os.FileMode) (*os.File, error) {
+	return os.OpenFile(string(e), flags, mode)
+}
+
+// Complete suggests files and directories matching partial. Directories end with a separator
+// so the user can keep completing inside them.
+func (e ExistingFilePath) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{}.Complete(ctx, partial)
+}
+
+// ByteSize is a number of bytes, written with an optional decimal (KB, MB, ...) or binary (KiB, MiB, ...)
+// unit such as 512, 1.5GB or 10MiB. Units are case insensitive and the B can be left out (10Mi, 64k).
+type ByteSize int64
+
+// byteUnits are the units a ByteSize can be written in, largest first within each system.
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"PB", 1e15}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+}
+
+func (b *ByteSize) Set(s string) error {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", s)
+	}
+	unit := strings.ToLower(strings.TrimSpace(s[i:]))
+	size := int64(1)
+	if unit != "" && unit != "b" {
+		size = 0
+		for _, u := range byteUnits {
+			name := strings.ToLower(u.name)
+			if unit == name || unit == strings.TrimSuffix(name, "b") {
+				size = u.size
+			}
+		}
+		if size == 0 {
+			return fmt.Errorf("unknown unit %q in size %q", s[i:], s)
+		}
+	}
+	if n*float64(size) >= math.MaxInt64 {
+		return fmt.Errorf("size %q is too large", s)
+	}
+	*b = ByteSize(math.Round(n * float64(size)))
+	return nil
+}
+
+// String renders the size in the largest unit that divides it, so it reads back the same.
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && int64(b)%u.size == 0 {
+			return fmt.Sprintf("%d%s", int64(b)/u.size, u.name)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
+
+func (b ByteSize) Type() string { return "size" }
+
+// URL is an absolute URL, one with a scheme. The `scheme` tag limits the schemes it accepts.
+type URL struct {
+	url.URL
+}
+
+func (u *URL) Set(s string) error {
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme == "" {
+		return fmt.Errorf("%q is missing a scheme", s)
+	}
+	u.URL = *parsed
+	return nil
+}
+
+func (u URL) String() string { return u.URL.String() }
+func (u URL) Type() string   { return "url" }
+
+// Port is a TCP or UDP port number, between 1 and 65535.
+type Port uint16
+
+func (p *Port) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", s)
+	}
+	if n < 1 || n > math.MaxUint16 {
+		return fmt.Errorf("port %d is out of range 1-%d", n, math.MaxUint16)
+	}
+	*p = Port(n)
+	return nil
+}
+
+func (p Port) String() string { return strconv.Itoa(int(p)) }
+func (p Port) Type() string   { return "port" }
+
+// HostPort is a host and port such as example.com:443, [::1]:8080 or :8080. The host can be empty
+// to listen on every interface, but the port is required.
+type HostPort struct {
+	Host string
+	Port Port
+}
+
+func (h *HostPort) Set(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return err
+	}
+	var p Port
+	if err := p.Set(port); err != nil {
+		return err
+	}
+	h.Host, h.Port = host, p
+	return nil
+}
+
+func (h HostPort) String() string {
+	if h == (HostPort{}) {
+		return ""
+	}
+	return net.JoinHostPort(h.Host, h.Port.String())
+}
+
+func (h HostPort) Type() string { return "host:port" }
@@ -2,6 +2,7 @@ package quack
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -168,3 +169,22 @@ func TestExistingFilePathRoundTrip(t *testing.T) {
 		assert.Equal(t, content, string(buf))
 	})
 }
+
+func TestExistingFilePathOpenE(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.Nil(t, os.WriteFile(path, []byte("content"), 0o644))
+
+	file, err := ExistingFilePath(path).OpenE()
+	require.Nil(t, err)
+	file.Close()
+	file, err = ExistingFilePath(path).OpenWithE(os.O_RDWR, 0)
+	require.Nil(t, err)
+	file.Close()
+
+	_, err = ExistingFilePath(path + ".missing").OpenE()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Panics(t, func() {
+		missing := ExistingFilePath(path + ".missing")
+		missing.Open()
+	})
+}
@@ -1,3 +1,5 @@
+//go:build !quack_nourfave
+
 package main
 
 import (
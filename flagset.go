@@ -0,0 +1,195 @@
+package quack
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BindFlagSet binds root to a *flag.FlagSet from the standard library. Parse the command line with the
+// returned FlagSet, then call run to execute the command. Groups pick their subcommand from the first
+// argument left after parsing, which parses its own flags from the rest.
+//
+// The flag package stops parsing at the first positional argument, so flags must come before
+// positional arguments and subcommand names. Short names are registered as extra flags.
+func BindFlagSet(name string, root any, opts ...BindOption) (*flag.FlagSet, func(ctx context.Context) error, error) {
+	rn, err := bind(name, root, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := flagValues{}
+	fs, err := rn.toFlagSet(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := func(ctx context.Context) error {
+		return rn.runFlagSet(ctx, fs, values)
+	}
+	return fs, run, nil
+}
+
+// flagValues holds the value of every flag passed to a command or the commands above it, by option.
+// A subcommand's flag set reuses the values of the globals, so repeated ones keep accumulating.
+type flagValues map[flagKey]*flagValue
+
+type flagKey struct {
+	o      *option
+	negate bool
+}
+
+func (vs flagValues) get(o *option, negate bool) *flagValue {
+	k := flagKey{o, negate}
+	if vs[k] == nil {
+		vs[k] = &flagValue{o: o, negate: negate}
+	}
+	return vs[k]
+}
+
+// isSet reports if the named flag, or its negation, was passed on the command line.
+func (vs flagValues) isSet(name string) bool {
+	for k, v := range vs {
+		if k.o.Name == name && v.changed {
+			return true
+		}
+	}
+	return false
+}
+
+// toFlagSet registers the flags that can be passed to c. The flag package has a single namespace for
+// long and short names, so a name given twice to the same option is registered once, and a name
+// given to two options is an error.
+func (c *node) toFlagSet(values flagValues) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet(strings.Join(c.path(), " "), flag.ContinueOnError)
+	owners := map[string]*option{}
+	register := func(v *flagValue, name, usage string) error {
+		if owner, ok := owners[name]; ok {
+			if owner == v.o {
+				return nil
+			}
+			return fmt.Errorf("%w: %s: flag %s of --%s is already used by --%s",
+				ErrInvalidOption, strings.Join(c.path(), " "), name, v.o.Name, owner.Name)
+		}
+		owners[name] = v.o
+		fs.Var(v, name, usage)
+		return nil
+	}
+	for _, o := range c.flagOptions() {
+		v := values.get(o, false)
+		for _, name := range append([]string{o.Name, o.Short}, o.Aliases...) {
+			if name == "" {
+				continue
+			}
+			if err := register(v, name, o.usage()); err != nil {
+				return nil, err
+			}
+		}
+		if o.negatable() {
+			if err := register(values.get(o, true), negationPrefix+o.Name, "turn off --"+o.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.cfg.configDefaults {
+		// every command takes --config, a subcommand's flag set keeps the path given before it
+		fs.StringVar(&c.cfg.configPath, configFlag, c.cfg.configPath, "config file to load")
+	}
+	fs.Usage = func() {
+		fmtHelp(fs.Output(), c)
+	}
+	return fs, nil
+}
+
+// runFlagSet runs c with the already parsed fs. values holds the flags passed to every command on the way.
+func (c *node) runFlagSet(ctx context.Context, fs *flag.FlagSet, values flagValues) error {
+	args := fs.Args()
+
+	if c.isGroup && len(args) > 0 {
+		sub := c.subcommand(args[0])
+		if sub == nil {
+			return fmt.Errorf("unknown command %q for %q", args[0], strings.Join(c.path(), " "))
+		}
+		if err := sub.load(); err != nil {
+			return err
+		}
+		subFlags, err := sub.toFlagSet(values)
+		if err != nil {
+			return err
+		}
+		subFlags.SetOutput(fs.Output())
+		if err := subFlags.Parse(args[1:]); err != nil {
+			return err
+		}
+		return sub.runFlagSet(ctx, subFlags, values)
+	}
+
+	c.warnDeprecated(fs.Output(), values.isSet)
+	ctx, err := c.prepare(ctx, args, values.isSet)
+	if err != nil {
+		return err
+	}
+	if c.isGroup {
+		if err := c.run(ctx, args); err != nil {
+			return err
+		}
+		fs.Usage()
+		return nil
+	}
+	if err := c.before(ctx); err != nil {
+		return err
+	}
+	return c.after(ctx, c.run(ctx, args))
+}
+
+// flagValue adapts an option to flag.Value.
+// Negating values set a bool option to the opposite of what they are given.
+type flagValue struct {
+	o       *option
+	changed bool
+	negate  bool
+}
+
+func (f *flagValue) Set(s string) error {
+	switch {
+	case f.negate:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		s = strconv.FormatBool(!b)
+	case f.o.Counter && s == "true":
+		// the flag package passes "true" to flags without a value
+		s = "+1"
+	}
+	if err := f.o.setValue(s, !f.changed); err != nil {
+		return err
+	}
+	f.changed = true
+	return nil
+}
+
+func (f *flagValue) String() string {
+	// flag calls String on a zero value to tell if the default should be printed
+	if f == nil || f.o == nil {
+		return ""
+	}
+	if f.negate {
+		return strconv.FormatBool(!f.o.Target.Bool())
+	}
+	return f.o.formatValue()
+}
+
+// Type makes flagValue a pflag.Value, so cobra can use it for types pflag doesn't support.
+func (f *flagValue) Type() string {
+	return f.o.typeName()
+}
+
+// Get makes flagValue a cli.Value, so urfave/cli can use it for types it doesn't support.
+func (f *flagValue) Get() any {
+	return f.o.Target.Interface()
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return !f.o.takesValue()
+}
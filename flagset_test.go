@@ -0,0 +1,174 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFlagSetParity runs the same command lines through the flag backend and every other backend,
+// and expects the same fields to be set and the same errors.
+func TestFlagSetParity(t *testing.T) {
+	tests := []struct {
+		name string
+		root func() any
+		args []string
+		env  map[string]string
+		err  string
+	}{
+		{
+			name: "defaults",
+			root: func() any { return new(defaulterCmd) },
+		},
+		{
+			name: "defaults overridden",
+			root: func() any { return new(defaulterCmd) },
+			args: []string{"--host", "example.com", "--tags", "c", "--tags", "d,e", "--level", "x", "there"},
+		},
+		{
+			name: "custom types",
+			root: func() any { return new(customTypesCmd) },
+			args: []string{"--version", "v2.3", "--level", "high", "--addr", "10.0.0.1", "--versions", "v1.0", "--versions", "v1.1", "v3.0", "v4.0", "v4.1"},
+		},
+		{
+			name: "invalid custom type",
+			root: func() any { return new(customTypesCmd) },
+			args: []string{"--level", "medium", "v3.0", "v4.0"},
+			err:  `unknown level "medium"`,
+		},
+		{
+			name: "env",
+			root: func() any { return new(envCmd) },
+			env:  map[string]string{"TEST_PORT": "9000", "TEST_HOSTS": "a,b", "TEST_NAME": "from-env"},
+		},
+		{
+			name: "flags over env",
+			root: func() any { return new(envCmd) },
+			args: []string{"--port", "1", "name"},
+			env:  map[string]string{"TEST_PORT": "9000"},
+		},
+		{
+			name: "groups",
+			root: func() any { return new(groupedCmd) },
+			args: []string{"-l", "debug", "--token", "abc", "--db-host", "db", "--ro-port", "1", "--region", "eu"},
+		},
+		{
+			name: "group validation",
+			root: func() any { return new(groupedCmd) },
+			args: []string{"--db-host", ""},
+			err:  "host is required",
+		},
+		{
+			name: "globals",
+			root: func() any { return newGlobalsRoot() },
+			args: []string{"-v", "leaf", "--name", "x"},
+		},
+		{
+			name: "nested globals",
+			root: func() any { return newGlobalsRoot() },
+			args: []string{"mid", "ctx", "--verbose"},
+		},
+		{
+			name: "globals before and after the subcommand",
+			root: func() any { return newGlobalsRoot() },
+			args: []string{"--tags", "a", "leaf", "--tags", "b"},
+		},
+		{
+			name: "short equal to the name",
+			root: func() any { return new(shortNameCmd) },
+			args: []string{"-v", "--x", "1"},
+		},
+		{
+			name: "short equal to another name",
+			root: func() any { return new(shortClashCmd) },
+			err:  "short name -x of --y is already used by --x",
+		},
+		{
+			name: "missing positional",
+			root: func() any { return new(positionalsOnlyCmd) },
+			err:  "missing required positional argument: source",
+		},
+	}
+	for backend, run := range backends {
+		if backend == "flag" {
+			continue
+		}
+		for _, test := range tests {
+			t.Run(backend+"/"+test.name, func(t *testing.T) {
+				for k, v := range test.env {
+					t.Setenv(k, v)
+				}
+				want := test.root()
+				err := run(t, want, test.args...)
+				if test.err != "" {
+					assert.ErrorContains(t, err, test.err)
+				} else {
+					assert.Nil(t, err)
+				}
+
+				got := test.root()
+				err = backends["flag"](t, got, test.args...)
+				if test.err != "" {
+					assert.ErrorContains(t, err, test.err)
+				} else {
+					assert.Nil(t, err)
+				}
+				assert.Equal(t, want, got)
+			})
+		}
+	}
+}
+
+type shortNameCmd struct {
+	V bool `short:"v"`
+	X int  `short:"x"`
+}
+
+func (s *shortNameCmd) Run([]string) {
+}
+
+type shortClashCmd struct {
+	X int
+	Y int `short:"x"`
+}
+
+func (s *shortClashCmd) Run([]string) {
+}
+
+type positionalsOnlyCmd struct {
+	Source string `arg:"1"`
+}
+
+func (p *positionalsOnlyCmd) Run([]string) {
+}
+
+func TestFlagSetHelp(t *testing.T) {
+	fs, _, err := BindFlagSet("app", newGlobalsRoot())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	fs.SetOutput(out)
+	assert.ErrorIs(t, fs.Parse([]string{"-h"}), flag.ErrHelp)
+	assert.Contains(t, out.String(), "app [command] [flags]")
+	assert.Contains(t, out.String(), "-v, --[no-]verbose")
+	assert.Contains(t, out.String(), "leaf")
+}
+
+func TestFlagSetGroupHelp(t *testing.T) {
+	fs, run, err := BindFlagSet("app", newGlobalsRoot())
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	fs.SetOutput(out)
+	assert.Nil(t, fs.Parse([]string{"mid"}))
+	assert.Nil(t, run(context.Background()))
+	assert.Contains(t, out.String(), "app mid [command] [flags]")
+}
+
+func TestFlagSetUnknownCommand(t *testing.T) {
+	fs, run, err := BindFlagSet("app", newGlobalsRoot())
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Parse([]string{"nope"}))
+	assert.ErrorContains(t, run(context.Background()), `unknown command "nope" for "app"`)
+}
@@ -3,72 +3,173 @@ package quack
 import (
 	"fmt"
 	"io"
+	"reflect"
+	"sort"
 	"strings"
 	"text/tabwriter"
-
-	"github.com/spf13/pflag"
+	"time"
 )
 
-func fmtUsage(w io.Writer, fs *pflag.FlagSet) {
+// fmtHelp renders the help of c for the native runtime, in the same layout as fmtUsage.
+func fmtHelp(w io.Writer, c *node) {
+	if c.long != "" {
+		fmt.Fprintf(w, "%s\n\n", c.long)
+	}
+
+	usage := strings.Join(c.path(), " ")
+	if len(c.subcommands) > 0 {
+		usage += " [command]"
+	}
+	usage += " [flags]"
+	for _, o := range c.positionalOptions {
+		arg := "<" + o.Name + ">"
+		if isRepeated(o.Target) {
+			arg += "..."
+		}
+		if o.Default != "" {
+			arg = "[" + arg + "]"
+		}
+		usage += " " + arg
+	}
+	fmt.Fprintf(w, "Usage:\n  %s\n", usage)
+
 	tw := tabwriter.NewWriter(w, 2, 2, 1, ' ', 0)
-	fs.SortFlags = true
-	var flags []string
-	var options []string
-	fs.VisitAll(func(f *pflag.Flag) {
-		var line strings.Builder
+	// commands without a category come first, then each category in the order it first appears
+	var categories []string
+	byCategory := map[string][]*node{}
+	for _, s := range c.subcommands {
+		if !s.inHelp() {
+			continue
+		}
+		if _, ok := byCategory[s.category]; !ok {
+			categories = append(categories, s.category)
+		}
+		byCategory[s.category] = append(byCategory[s.category], s)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i] == "" && categories[j] != ""
+	})
+	for _, category := range categories {
+		heading := category
+		if heading == "" {
+			heading = "Commands"
+		}
+		fmt.Fprintf(tw, "\n%s:\n", heading)
+		for _, s := range byCategory[category] {
+			fmt.Fprintf(tw, "\t%s\t%s\n", s.name, s.short)
+		}
+	}
+	if len(c.positionalOptions) > 0 {
+		fmt.Fprintln(tw, "\nArguments:")
+		for _, o := range c.positionalOptions {
+			fmt.Fprintf(tw, "\t%s\t%s\n", o.Name, o.usage())
+		}
+	}
+	tw.Flush()
 
-		if f.Shorthand != "" {
-			fmt.Fprintf(&line, "\t-%s,\t--%s", f.Shorthand, f.Name)
+	options := c.flagOptions()
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Name < options[j].Name
+	})
+	var flags, values []string
+	flags = append(flags, fmt.Sprintf("\t-h,\t--help\t\t\thelp for %s", c.name))
+	if c.cfg.configDefaults {
+		values = append(values, fmt.Sprintf("\t\t--%s\tstring\t\tconfig file to load", configFlag))
+	}
+	for _, o := range options {
+		if !o.inHelp() {
+			continue
+		}
+		var line strings.Builder
+		name := o.Name
+		if o.negatable() {
+			name = "[" + negationPrefix + "]" + name
+		}
+		if o.Short != "" {
+			fmt.Fprintf(&line, "\t-%s,\t--%s", o.Short, name)
 		} else {
-			fmt.Fprintf(&line, "\t\t--%s", f.Name)
+			fmt.Fprintf(&line, "\t\t--%s", name)
 		}
 		line.WriteByte('\t')
-
-		valType := f.Value.Type()
-
-		typeName, usage := pflag.UnquoteUsage(f)
-		if typeName != "" {
-			line.WriteString(typeName)
+		if o.takesValue() {
+			line.WriteString(o.typeName())
 		}
-
 		line.WriteByte('\t')
-		if f.DefValue != "" {
-			switch valType {
-			case "string":
-				fmt.Fprintf(&line, "(default='%s')", f.DefValue)
-			case "bool":
-				fmt.Fprintf(&line, "(default=%s)", f.DefValue)
-
-			default:
-				fmt.Fprintf(&line, "(default=%s)", f.DefValue)
-
+		if o.Default != "" {
+			if o.Target.Kind() == reflect.String {
+				fmt.Fprintf(&line, "(default='%s')", o.Default)
+			} else {
+				fmt.Fprintf(&line, "(default=%s)", o.Default)
 			}
-
 		}
 		line.WriteByte('\t')
-		line.WriteString(usage)
+		line.WriteString(o.usage())
 
-		switch valType {
-		case "bool":
+		if !o.takesValue() {
 			flags = append(flags, line.String())
-		default:
-			options = append(options, line.String())
+		} else {
+			values = append(values, line.String())
 		}
+	}
 
-	})
-
+	tw = tabwriter.NewWriter(w, 2, 2, 1, ' ', 0)
 	for i, f := range flags {
 		if i == 0 {
-			fmt.Fprintln(tw, "Flags:\t\t\t\t\t")
+			fmt.Fprintln(tw, "\nFlags:\t\t\t\t\t")
 		}
 		fmt.Fprintln(tw, f)
 	}
-	for i, o := range options {
+	for i, o := range values {
 		if i == 0 {
-			fmt.Fprintln(tw, "Options:\t\t\t\t\t")
+			fmt.Fprintln(tw, "\nOptions:\t\t\t\t\t")
 		}
 		fmt.Fprintln(tw, o)
 	}
-
 	tw.Flush()
 }
+
+// inHelp reports if the command is listed in the help of its parent.
+func (c *node) inHelp() bool {
+	return !c.hidden && c.deprecated == ""
+}
+
+// inHelp reports if the option is listed in help.
+func (o *option) inHelp() bool {
+	return !o.Hidden && o.Deprecated == ""
+}
+
+// typeName is the type of value an option takes, as shown in help.
+func (o *option) typeName() string {
+	if o.Counter {
+		return "count"
+	}
+	v := o.Target
+	if o.Layout != "" && v.Type() == timeType {
+		return o.Layout
+	}
+	if usesCustomValue(v) {
+		return newCustomValue(v).Type()
+	}
+	if isSlice(v) {
+		return scalarTypeName(v.Type().Elem()) + "s"
+	}
+	if isMap(v) {
+		return elemTypeName(v.Type().Key()) + o.separator() + elemTypeName(v.Type().Elem())
+	}
+	return scalarTypeName(v.Type())
+}
+
+// elemTypeName is the type name of the keys and values of a map.
+func elemTypeName(t reflect.Type) string {
+	if isCustomElem(t) {
+		return customTypeName(t)
+	}
+	return scalarTypeName(t)
+}
+
+func scalarTypeName(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "duration"
+	}
+	return t.Kind().String()
+}
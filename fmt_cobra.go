@@ -0,0 +1,83 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+func fmtUsage(w io.Writer, fs *pflag.FlagSet) {
+	tw := tabwriter.NewWriter(w, 2, 2, 1, ' ', 0)
+	fs.SortFlags = true
+	var flags []string
+	var options []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		var line strings.Builder
+
+		name := f.Name
+		if negation := fs.Lookup(negationPrefix + f.Name); negation != nil && negation.Hidden {
+			name = "[" + negationPrefix + "]" + name
+		}
+		if f.Shorthand != "" {
+			fmt.Fprintf(&line, "\t-%s,\t--%s", f.Shorthand, name)
+		} else {
+			fmt.Fprintf(&line, "\t\t--%s", name)
+		}
+		line.WriteByte('\t')
+
+		valType := f.Value.Type()
+
+		typeName, usage := pflag.UnquoteUsage(f)
+		if typeName != "" && valType != "count" {
+			line.WriteString(typeName)
+		}
+
+		line.WriteByte('\t')
+		if f.DefValue != "" {
+			switch valType {
+			case "string":
+				fmt.Fprintf(&line, "(default='%s')", f.DefValue)
+			case "bool":
+				fmt.Fprintf(&line, "(default=%s)", f.DefValue)
+
+			default:
+				fmt.Fprintf(&line, "(default=%s)", f.DefValue)
+
+			}
+
+		}
+		line.WriteByte('\t')
+		line.WriteString(usage)
+
+		switch valType {
+		case "bool", "count":
+			flags = append(flags, line.String())
+		default:
+			options = append(options, line.String())
+		}
+
+	})
+
+	for i, f := range flags {
+		if i == 0 {
+			fmt.Fprintln(tw, "Flags:\t\t\t\t\t")
+		}
+		fmt.Fprintln(tw, f)
+	}
+	for i, o := range options {
+		if i == 0 {
+			fmt.Fprintln(tw, "Options:\t\t\t\t\t")
+		}
+		fmt.Fprintln(tw, o)
+	}
+
+	tw.Flush()
+}
@@ -0,0 +1,137 @@
+package quack
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// parseTyped parses value into the typed pointer p from generated code.
+// It reports false for types it doesn't know, which are parsed through reflection instead.
+func parseTyped(p any, value string) (bool, error) {
+	switch p := p.(type) {
+	case *string:
+		*p = value
+		return true, nil
+	case *bool:
+		return true, parseInto(p, value, parseBool)
+	case *int:
+		return true, parseInto(p, value, parseInt[int](strconv.IntSize))
+	case *int8:
+		return true, parseInto(p, value, parseInt[int8](8))
+	case *int16:
+		return true, parseInto(p, value, parseInt[int16](16))
+	case *int32:
+		return true, parseInto(p, value, parseInt[int32](32))
+	case *int64:
+		return true, parseInto(p, value, parseInt[int64](64))
+	case *uint:
+		return true, parseInto(p, value, parseUint[uint](strconv.IntSize))
+	case *uint8:
+		return true, parseInto(p, value, parseUint[uint8](8))
+	case *uint16:
+		return true, parseInto(p, value, parseUint[uint16](16))
+	case *uint32:
+		return true, parseInto(p, value, parseUint[uint32](32))
+	case *uint64:
+		return true, parseInto(p, value, parseUint[uint64](64))
+	case *float32:
+		return true, parseInto(p, value, parseFloat[float32](32))
+	case *float64:
+		return true, parseInto(p, value, parseFloat[float64](64))
+	case *time.Duration:
+		return true, parseInto(p, value, parseDuration)
+	}
+	return false, nil
+}
+
+// appendTyped parses value and appends it to the slice behind the typed pointer p from generated code.
+// It reports false for types it doesn't know, which are parsed through reflection instead.
+func appendTyped(p any, value string) (bool, error) {
+	switch p := p.(type) {
+	case *[]string:
+		*p = append(*p, value)
+		return true, nil
+	case *[]bool:
+		return true, appendInto(p, value, parseBool)
+	case *[]int:
+		return true, appendInto(p, value, parseInt[int](strconv.IntSize))
+	case *[]int32:
+		return true, appendInto(p, value, parseInt[int32](32))
+	case *[]int64:
+		return true, appendInto(p, value, parseInt[int64](64))
+	case *[]uint:
+		return true, appendInto(p, value, parseUint[uint](strconv.IntSize))
+	case *[]float32:
+		return true, appendInto(p, value, parseFloat[float32](32))
+	case *[]float64:
+		return true, appendInto(p, value, parseFloat[float64](64))
+	}
+	return false, nil
+}
+
+func parseInto[T any](p *T, value string, parse func(string) (T, error)) error {
+	v, err := parse(value)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+func appendInto[T any](p *[]T, value string, parse func(string) (T, error)) error {
+	v, err := parse(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, v)
+	return nil
+}
+
+// The parsers below return the same errors as parseValue.
+
+func parseBool(s string) (bool, error) {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean: %w", err)
+	}
+	return b, nil
+}
+
+func parseInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](bits int) func(string) (T, error) {
+	return func(s string) (T, error) {
+		i, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer: %w", err)
+		}
+		return T(i), nil
+	}
+}
+
+func parseUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](bits int) func(string) (T, error) {
+	return func(s string) (T, error) {
+		u, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid unsigned integer: %w", err)
+		}
+		return T(u), nil
+	}
+}
+
+func parseFloat[T ~float32 | ~float64](bits int) func(string) (T, error) {
+	return func(s string) (T, error) {
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid float: %w", err)
+		}
+		return T(f), nil
+	}
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	return d, nil
+}
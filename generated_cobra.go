@@ -0,0 +1,67 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// setTypedFlag registers the option through the typed pointer from generated code.
+// It reports false for types it doesn't know, which are registered through reflection instead.
+// The field already holds its default, so the current value is the flag's default.
+func (o *option) setTypedFlag(fs *pflag.FlagSet) bool {
+	name, short, help := o.Name, o.Short, o.usage()
+	switch p := o.ptr.(type) {
+	case *string:
+		fs.StringVarP(p, name, short, *p, help)
+	case *bool:
+		fs.BoolVarP(p, name, short, *p, help)
+	case *int:
+		fs.IntVarP(p, name, short, *p, help)
+	case *int8:
+		fs.Int8VarP(p, name, short, *p, help)
+	case *int16:
+		fs.Int16VarP(p, name, short, *p, help)
+	case *int32:
+		fs.Int32VarP(p, name, short, *p, help)
+	case *int64:
+		fs.Int64VarP(p, name, short, *p, help)
+	case *uint:
+		fs.UintVarP(p, name, short, *p, help)
+	case *uint8:
+		fs.Uint8VarP(p, name, short, *p, help)
+	case *uint16:
+		fs.Uint16VarP(p, name, short, *p, help)
+	case *uint32:
+		fs.Uint32VarP(p, name, short, *p, help)
+	case *uint64:
+		fs.Uint64VarP(p, name, short, *p, help)
+	case *float32:
+		fs.Float32VarP(p, name, short, *p, help)
+	case *float64:
+		fs.Float64VarP(p, name, short, *p, help)
+	case *time.Duration:
+		fs.DurationVarP(p, name, short, *p, help)
+	case *[]string:
+		fs.StringSliceVarP(p, name, short, *p, help)
+	case *[]bool:
+		fs.BoolSliceVarP(p, name, short, *p, help)
+	case *[]int:
+		fs.IntSliceVarP(p, name, short, *p, help)
+	case *[]int32:
+		fs.Int32SliceVarP(p, name, short, *p, help)
+	case *[]int64:
+		fs.Int64SliceVarP(p, name, short, *p, help)
+	case *[]uint:
+		fs.UintSliceVarP(p, name, short, *p, help)
+	case *[]float32:
+		fs.Float32SliceVarP(p, name, short, *p, help)
+	case *[]float64:
+		fs.Float64SliceVarP(p, name, short, *p, help)
+	default:
+		return false
+	}
+	return true
+}
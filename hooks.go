@@ -0,0 +1,32 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// before runs the Before hooks of every command from the root to c.
+func (c *node) before(ctx context.Context) error {
+	for _, n := range c.lineage() {
+		h, ok := n.target.(BeforeHook)
+		if !ok {
+			continue
+		}
+		if err := h.Before(ctx); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(n.path(), " "), err)
+		}
+	}
+	return nil
+}
+
+// after runs the After hooks of every command from c to the root, threading err through each of them.
+func (c *node) after(ctx context.Context, err error) error {
+	lineage := c.lineage()
+	for i := len(lineage) - 1; i >= 0; i-- {
+		if h, ok := lineage[i].target.(AfterHook); ok {
+			err = h.After(ctx, err)
+		}
+	}
+	return err
+}
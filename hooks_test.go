@@ -0,0 +1,126 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hookLog struct {
+	events []string
+}
+
+func (h *hookLog) add(e string) {
+	h.events = append(h.events, e)
+}
+
+type hookRoot struct {
+	log       *hookLog
+	failFirst bool
+	leaf      *hookLeaf
+}
+
+func (h *hookRoot) SubCommands() Map {
+	return Map{"mid": &hookMid{log: h.log, leaf: h.leaf}}
+}
+
+func (h *hookRoot) Before(ctx context.Context) error {
+	h.log.add("before root")
+	if h.failFirst {
+		return errors.New("no connection")
+	}
+	return nil
+}
+
+func (h *hookRoot) After(ctx context.Context, err error) error {
+	h.log.add("after root")
+	return err
+}
+
+type hookMid struct {
+	log  *hookLog
+	leaf *hookLeaf
+}
+
+func (h *hookMid) SubCommands() Map {
+	return Map{"leaf": h.leaf}
+}
+
+type hookLeaf struct {
+	Fail     bool
+	Suppress bool
+	log      *hookLog
+}
+
+func (h *hookLeaf) Before(ctx context.Context) error {
+	h.log.add("before leaf")
+	return nil
+}
+
+func (h *hookLeaf) RunE(ctx context.Context, args []string) error {
+	h.log.add("run")
+	if h.Fail {
+		return errors.New("run failed")
+	}
+	return nil
+}
+
+func (h *hookLeaf) After(ctx context.Context, err error) error {
+	h.log.add("after leaf")
+	if h.Suppress {
+		return nil
+	}
+	return err
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		failFirst bool
+		args      []string
+		err       string
+		events    []string
+	}{
+		{
+			name:   "order",
+			args:   []string{"mid", "leaf"},
+			events: []string{"before root", "before leaf", "run", "after leaf", "after root"},
+		},
+		{
+			name:      "before_error",
+			failFirst: true,
+			args:      []string{"mid", "leaf"},
+			err:       "app: no connection",
+			events:    []string{"before root"},
+		},
+		{
+			name:   "run_error",
+			args:   []string{"mid", "leaf", "--fail"},
+			err:    "run failed",
+			events: []string{"before root", "before leaf", "run", "after leaf", "after root"},
+		},
+		{
+			name:   "after_replaces_error",
+			args:   []string{"mid", "leaf", "--fail", "--suppress"},
+			events: []string{"before root", "before leaf", "run", "after leaf", "after root"},
+		},
+	}
+	for backend, run := range backends {
+		for _, test := range tests {
+			t.Run(backend+"/"+test.name, func(t *testing.T) {
+				log := new(hookLog)
+				root := &hookRoot{log: log, failFirst: test.failFirst, leaf: &hookLeaf{log: log}}
+
+				err := run(t, root, test.args...)
+				if test.err == "" {
+					assert.Nil(t, err)
+				} else {
+					assert.EqualError(t, err, test.err)
+				}
+				assert.Equal(t, test.events, log.events)
+			})
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package gotypes reads command structs from type-checked source the way quack reads them through
+// reflection, for quackgen and quackvet. It is kept apart from quack so programs don't link go/types.
+package gotypes
+
+import "go/types"
+
+var (
+	stringType = types.Typ[types.String]
+	bytesType  = types.NewSlice(types.Typ[types.Byte])
+	errorType  = types.Universe.Lookup("error").Type()
+)
+
+// IsCustom reports if t parses itself through pflag.Value, quack.Parser or encoding.TextUnmarshaler.
+// Like the interfaces quack checks at runtime, the methods must have the exact signatures.
+func IsCustom(t types.Type) bool {
+	methods := types.NewMethodSet(types.NewPointer(t))
+	has := func(name string, param, result types.Type) bool {
+		sel := methods.Lookup(nil, name)
+		if sel == nil {
+			return false
+		}
+		sig := sel.Type().(*types.Signature)
+		if sig.Variadic() || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), result) {
+			return false
+		}
+		if param == nil {
+			return sig.Params().Len() == 0
+		}
+		return sig.Params().Len() == 1 && types.Identical(sig.Params().At(0).Type(), param)
+	}
+	isValue := has("Set", stringType, errorType) && has("String", nil, stringType) && has("Type", nil, stringType)
+	return isValue || has("Parse", stringType, errorType) || has("UnmarshalText", bytesType, errorType)
+}
+
+// GroupStruct returns the struct of options t holds, if it is an option group rather than a single option.
+// isArg reports if the field has an `arg` tag, which makes it a single positional argument.
+func GroupStruct(t types.Type, isArg bool) (*types.Struct, bool) {
+	if isArg || IsCustom(t) {
+		return nil, false
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
@@ -0,0 +1,139 @@
+package quack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lazyGlobals struct {
+	Verbose bool `short:"v"`
+}
+
+type lazyServe struct {
+	Port   int `default:"80"`
+	Global *lazyGlobals
+	ran    bool
+}
+
+func (s *lazyServe) Run([]string)      { s.ran = true }
+func (s *lazyServe) ShortHelp() string { return "serve things" }
+
+// lazyBroken has a default that doesn't parse, which is only reported when it runs.
+type lazyBroken struct {
+	Count int `default:"many"`
+}
+
+func (lazyBroken) Run([]string) {}
+
+type lazyDB struct {
+	migrate lazyServe
+}
+
+func (d *lazyDB) SubCommands() Map {
+	return Map{"migrate": &d.migrate}
+}
+
+type lazyRoot struct {
+	g     lazyGlobals
+	serve lazyServe
+	db    lazyDB
+}
+
+func (r *lazyRoot) Globals() any {
+	return &r.g
+}
+
+func (r *lazyRoot) SubCommands() Map {
+	return Map{
+		"serve":  &r.serve,
+		"db":     &r.db,
+		"broken": &lazyBroken{},
+	}
+}
+
+func TestLazy(t *testing.T) {
+	_, err := bind("app", &lazyRoot{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidOption, "eager binding reports the broken command")
+
+	for name, run := range backendsWith(WithLazy()) {
+		t.Run(name, func(t *testing.T) {
+			root := &lazyRoot{}
+			assert.NoError(t, run(t, root, "serve", "--port", "9", "-v"))
+			assert.True(t, root.serve.ran)
+			assert.Equal(t, 9, root.serve.Port)
+			assert.Same(t, &root.g, root.serve.Global)
+			assert.True(t, root.g.Verbose)
+
+			root = &lazyRoot{}
+			assert.NoError(t, run(t, root, "db", "migrate"))
+			assert.True(t, root.db.migrate.ran)
+			assert.Equal(t, 80, root.db.migrate.Port)
+
+			err := run(t, &lazyRoot{}, "broken")
+			assert.ErrorIs(t, err, ErrInvalidOption)
+			assert.ErrorContains(t, err, `invalid default value "many" for count`)
+		})
+	}
+}
+
+type lazyRegion struct {
+	Region string
+}
+
+type lazyDeploy struct {
+	Region *lazyRegion
+	ran    bool
+}
+
+func (d *lazyDeploy) Run([]string) { d.ran = true }
+
+// lazyCloud is a group between the root and its leaf that has globals of its own.
+type lazyCloud struct {
+	g      lazyRegion
+	deploy lazyDeploy
+}
+
+func (c *lazyCloud) Globals() any { return &c.g }
+
+func (c *lazyCloud) SubCommands() Map {
+	return Map{"deploy": &c.deploy}
+}
+
+type lazyCloudRoot struct {
+	cloud lazyCloud
+}
+
+func (r *lazyCloudRoot) SubCommands() Map {
+	return Map{"cloud": &r.cloud}
+}
+
+func TestLazyGroupGlobals(t *testing.T) {
+	for name, run := range backendsWith(WithLazy()) {
+		t.Run(name, func(t *testing.T) {
+			root := &lazyCloudRoot{}
+			assert.NoError(t, run(t, root, "cloud", "deploy", "--region", "eu"))
+			assert.True(t, root.cloud.deploy.ran)
+			assert.Same(t, &root.cloud.g, root.cloud.deploy.Region)
+			assert.Equal(t, "eu", root.cloud.g.Region)
+		})
+	}
+}
+
+func TestLazyPending(t *testing.T) {
+	rn, err := bind("app", &lazyRoot{}, []BindOption{WithLazy()})
+	assert.NoError(t, err)
+	assert.NotNil(t, rn.globals, "the root is bound up front")
+
+	serve := rn.subcommand("serve")
+	assert.True(t, serve.pending)
+	assert.Empty(t, serve.options)
+	assert.Equal(t, "serve things", serve.short)
+	migrate := rn.subcommand("db").subcommand("migrate")
+	assert.True(t, migrate.pending)
+
+	assert.NoError(t, serve.load())
+	assert.False(t, serve.pending)
+	assert.Len(t, serve.options, 1)
+	assert.True(t, migrate.pending, "only the path being run is bound")
+}
@@ -0,0 +1,110 @@
+package quack
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Policies for a key given more than once to a map option, set with the `duplicates` tag.
+const (
+	duplicatesLast  = "last" // the last value wins, the default
+	duplicatesFirst = "first"
+	duplicatesError = "error"
+)
+
+// isMap reports if the target is a map option, passed as repeated key=value entries.
+func isMap(v reflect.Value) bool {
+	return v.Kind() == reflect.Map && !isCustom(v.Type())
+}
+
+// isRepeated reports if the target takes any number of values, as slices and maps do.
+func isRepeated(v reflect.Value) bool {
+	return isSlice(v) || isMap(v)
+}
+
+// separator is what splits the keys from the values of a map option.
+func (o *option) separator() string {
+	if o.Sep == "" {
+		return "="
+	}
+	return o.Sep
+}
+
+// putValue parses a key=value entry into a map field.
+func (o *option) putValue(entry string) error {
+	k, value, ok := strings.Cut(entry, o.separator())
+	if !ok {
+		return fmt.Errorf("expected key%svalue, got %q", o.separator(), entry)
+	}
+	key := reflect.New(o.Target.Type().Key()).Elem()
+	if err := parseScalar(key, k); err != nil {
+		return fmt.Errorf("invalid key %q: %w", k, err)
+	}
+	return o.putEntry(key, value)
+}
+
+// putEntry parses value and stores it under key, following the option's policy for duplicate keys.
+func (o *option) putEntry(key reflect.Value, value string) error {
+	m := o.Target
+	elem := reflect.New(m.Type().Elem()).Elem()
+	if err := parseScalar(elem, value); err != nil {
+		return fmt.Errorf("invalid value for key %v: %w", key, err)
+	}
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	if m.MapIndex(key).IsValid() {
+		switch o.Duplicates {
+		case duplicatesFirst:
+			return nil
+		case duplicatesError:
+			return fmt.Errorf("duplicate key %v", key)
+		}
+	}
+	m.SetMapIndex(key, elem)
+	return nil
+}
+
+// setConfigMap assigns a decoded config value to a map field. Config files can hold a table,
+// or a list of key=value entries like the command line.
+func (o *option) setConfigMap(raw any) error {
+	o.Target.Set(reflect.Zero(o.Target.Type()))
+	table, ok := raw.(map[string]any)
+	if !ok {
+		elems, ok := raw.([]any)
+		if !ok {
+			elems = []any{raw}
+		}
+		for _, e := range elems {
+			if err := o.putValue(configString(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, k := range slices.Sorted(maps.Keys(table)) {
+		key := reflect.New(o.Target.Type().Key()).Elem()
+		if err := parseScalar(key, k); err != nil {
+			return fmt.Errorf("invalid key %q: %w", k, err)
+		}
+		if err := o.putEntry(key, configString(table[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatMap renders a map as comma separated key=value entries, sorted by key.
+func (o *option) formatMap() string {
+	m := o.Target
+	entries := make([]string, 0, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		entries = append(entries, formatScalar(iter.Key())+o.separator()+formatScalar(iter.Value()))
+	}
+	slices.Sort(entries)
+	return strings.Join(entries, ",")
+}
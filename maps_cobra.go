@@ -0,0 +1,32 @@
+//go:build !quack_nocobra
+
+package quack
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// setMapFlag registers a map option with pflag's own map flags when they parse it the same way,
+// and through flagValue otherwise.
+func (o *option) setMapFlag(fs *pflag.FlagSet) {
+	v := o.Target
+	name, short, help := o.Name, o.Short, o.usage()
+	builtin := o.Sep == "" && o.Duplicates == "" && v.Type().Key().Kind() == reflect.String &&
+		!isCustom(v.Type().Key()) && !isCustom(v.Type().Elem()) && v.Type().Elem() != reflect.TypeOf(time.Duration(0))
+	switch {
+	case builtin && v.Type().Elem().Kind() == reflect.String:
+		p := rawAddr[map[string]string](v)
+		fs.StringToStringVarP(p, name, short, *p, help)
+	case builtin && v.Type().Elem().Kind() == reflect.Int:
+		p := rawAddr[map[string]int](v)
+		fs.StringToIntVarP(p, name, short, *p, help)
+	case builtin && v.Type().Elem().Kind() == reflect.Int64:
+		p := rawAddr[map[string]int64](v)
+		fs.StringToInt64VarP(p, name, short, *p, help)
+	default:
+		fs.VarP(&flagValue{o: o}, name, short, help)
+	}
+}
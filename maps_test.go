@@ -0,0 +1,89 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deployLabels map[string]string
+
+type mapCmd struct {
+	Label    deployLabels   `short:"l" env:"MAP_TEST_LABELS"`
+	Limit    map[string]int `default:"cpu=2"`
+	Timeouts map[string]time.Duration
+	Arg      map[string]string `sep:":" duplicates:"error"`
+	Ports    map[uint16]bool   `duplicates:"first"`
+	Build    map[string]string `arg:"1"`
+}
+
+func (m *mapCmd) Run([]string) {}
+
+func TestMaps(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &mapCmd{}
+			assert.NoError(t, run(t, cmd,
+				"-l", "team=infra", "--label", "tier=web,env=prod",
+				"--limit", "mem=512",
+				"--timeouts", "read=5s",
+				"--arg", "GOOS:linux", "--arg", "GOARCH:arm64",
+				"--ports", "80=true", "--ports", "80=false",
+				"VERSION=1.2", "COMMIT=abc",
+			))
+			assert.Equal(t, deployLabels{"team": "infra", "tier": "web", "env": "prod"}, cmd.Label)
+			assert.Equal(t, map[string]int{"mem": 512}, cmd.Limit)
+			assert.Equal(t, map[string]time.Duration{"read": 5 * time.Second}, cmd.Timeouts)
+			assert.Equal(t, map[string]string{"GOOS": "linux", "GOARCH": "arm64"}, cmd.Arg)
+			assert.Equal(t, map[uint16]bool{80: true}, cmd.Ports)
+			assert.Equal(t, map[string]string{"VERSION": "1.2", "COMMIT": "abc"}, cmd.Build)
+
+			cmd = &mapCmd{}
+			assert.NoError(t, run(t, cmd, "A=1"))
+			assert.Equal(t, map[string]int{"cpu": 2}, cmd.Limit)
+
+			assert.ErrorContains(t, run(t, &mapCmd{}, "--arg", "a:1", "--arg", "a:2", "A=1"), "duplicate key a")
+			assert.ErrorContains(t, run(t, &mapCmd{}, "--timeouts", "read", "A=1"), `expected key=value, got "read"`)
+			assert.ErrorContains(t, run(t, &mapCmd{}, "--ports", "http=true", "A=1"), `invalid key "http"`)
+			assert.ErrorContains(t, run(t, &mapCmd{}, "A"), `expected key=value, got "A"`)
+		})
+	}
+}
+
+func TestMapsEnvAndConfig(t *testing.T) {
+	t.Setenv("MAP_TEST_LABELS", "team=infra,tier=db")
+	path := writeConfig(t, t.TempDir(), "app.json", `{"limit": {"cpu": 4, "mem": 1024}, "arg": ["GOOS:darwin"]}`)
+	for name, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run(name, func(t *testing.T) {
+			cmd := &mapCmd{}
+			assert.NoError(t, run(t, cmd, "A=1"))
+			assert.Equal(t, deployLabels{"team": "infra", "tier": "db"}, cmd.Label)
+			assert.Equal(t, map[string]int{"cpu": 4, "mem": 1024}, cmd.Limit)
+			assert.Equal(t, map[string]string{"GOOS": "darwin"}, cmd.Arg)
+		})
+	}
+}
+
+func TestMapsInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Labels map[string]string `duplicates:"merge"`
+		Nested map[string][]string
+	}{}, nil)
+	require.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, `duplicates of labels must be last, first or error, got "merge"`)
+	assert.ErrorContains(t, err, "map[string][]string for nested")
+}
+
+func TestMapsHelp(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &mapCmd{}, []string{"--help"}, WithOutput(&out)))
+	assert.Contains(t, out.String(), "<build>...")
+	assert.Regexp(t, `--limit +string=int +\(default=cpu=2\)`, out.String())
+	assert.Regexp(t, `--arg +string:string`, out.String())
+	assert.Regexp(t, `--ports +uint16=bool`, out.String())
+}
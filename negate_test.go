@@ -0,0 +1,96 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type toggleCmd struct {
+	Cache   bool `default:"true" env:"TOGGLE_CACHE"`
+	Color   bool `nonegate:""`
+	Verbose int  `short:"v" counter:""`
+	Depth   int  `counter:"" default:"2"`
+}
+
+func (t *toggleCmd) Run([]string) {}
+
+func TestNegate(t *testing.T) {
+	t.Setenv("TOGGLE_CACHE", "true")
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &toggleCmd{}
+			assert.NoError(t, run(t, cmd, "--no-cache", "--color"))
+			assert.False(t, cmd.Cache)
+			assert.True(t, cmd.Color)
+
+			cmd = &toggleCmd{}
+			assert.NoError(t, run(t, cmd))
+			assert.True(t, cmd.Cache)
+
+			assert.Error(t, run(t, &toggleCmd{}, "--no-color"))
+		})
+	}
+}
+
+func TestCounter(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &toggleCmd{}
+			assert.NoError(t, run(t, cmd, "-v", "--verbose", "-v", "--depth"))
+			assert.Equal(t, 3, cmd.Verbose)
+			assert.Equal(t, 3, cmd.Depth)
+
+			cmd = &toggleCmd{}
+			assert.NoError(t, run(t, cmd))
+			assert.Equal(t, 0, cmd.Verbose)
+			assert.Equal(t, 2, cmd.Depth)
+		})
+	}
+	for name, run := range backends {
+		if name == "flag" {
+			// the flag package doesn't bundle short options
+			continue
+		}
+		t.Run(name+" bundled", func(t *testing.T) {
+			cmd := &toggleCmd{}
+			assert.NoError(t, run(t, cmd, "-vvv", "--depth=5"))
+			assert.Equal(t, 3, cmd.Verbose)
+			assert.Equal(t, 5, cmd.Depth)
+		})
+	}
+}
+
+func TestCounterInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Verbose bool `counter:""`
+	}{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "counter verbose must be an int, got bool")
+
+	type level int
+	_, err = bind("app", &struct {
+		simpleCmd
+		Verbose level `counter:""`
+	}{}, nil)
+	assert.ErrorContains(t, err, "counter verbose must be an int, got quack.level")
+
+	_, err = bind("app", &struct {
+		simpleCmd
+		Cache   bool
+		NoCache bool
+	}{}, nil)
+	assert.ErrorContains(t, err, "duplicate flag --no-cache")
+}
+
+func TestNegateHelp(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &toggleCmd{}, []string{"--help"}, WithOutput(&out)))
+	assert.Regexp(t, `--\[no-\]cache +\(default=true\)`, out.String())
+	assert.Regexp(t, `-v, --verbose +\n`, out.String())
+	assert.NotContains(t, out.String(), "[no-]color")
+	assert.NotContains(t, out.String(), "count")
+}
@@ -0,0 +1,130 @@
+package quack
+
+import (
+	"io"
+	"os"
+)
+
+// BindOption configures how a structure is bound to a cli framework.
+type BindOption func(*bindConfig)
+
+type bindConfig struct {
+	autoEnv      bool
+	envPrefix    string
+	envSeparator string
+
+	completion bool
+	out        io.Writer // where Run writes help
+	errOut     io.Writer // where Run writes warnings
+
+	reflectOnly bool // ignore Generated bindings
+	lazy        bool // bind the options of subcommands when they run
+
+	configDefaults bool
+	configFiles    []string
+	configPath     string // set by the --config flag
+}
+
+func (b *bindConfig) configEnabled() bool {
+	return b.configDefaults || len(b.configFiles) > 0
+}
+
+func newBindConfig(opts []BindOption) *bindConfig {
+	cfg := &bindConfig{
+		envSeparator: ",",
+		out:          os.Stdout,
+		errOut:       os.Stderr,
+	}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return cfg
+}
+
+// WithAutoEnv derives an environment variable for every option from the command path and the field name.
+// For example the field Port on the command "myapp serve" is read from MYAPP_SERVE_PORT.
+// Options with an `env` tag keep the name from the tag.
+func WithAutoEnv() BindOption {
+	return func(c *bindConfig) {
+		c.autoEnv = true
+	}
+}
+
+// WithEnvPrefix is like WithAutoEnv but replaces the name of the root command with prefix.
+func WithEnvPrefix(prefix string) BindOption {
+	return func(c *bindConfig) {
+		c.autoEnv = true
+		c.envPrefix = prefix
+	}
+}
+
+// WithEnvSeparator sets the separator used to split environment variables into repeated options.
+// The default is ",".
+func WithEnvSeparator(sep string) BindOption {
+	return func(c *bindConfig) {
+		c.envSeparator = sep
+	}
+}
+
+// WithConfig loads options from config files and adds a --config flag to the root command.
+// Files are loaded from the following locations, later ones overriding earlier ones:
+//
+//  1. $XDG_CONFIG_HOME/<name>/config.{json,yaml,yml,toml}
+//  2. .<name>.{json,yaml,yml,toml} in the working directory
+//  3. files passed to WithConfigFiles
+//  4. the file passed with --config
+//
+// Keys are the long names of the root command's options, subcommands are nested sections.
+// Flags and environment variables take precedence over config files.
+func WithConfig() BindOption {
+	return func(c *bindConfig) {
+		c.configDefaults = true
+	}
+}
+
+// WithConfigFiles loads options from the given config files, in order, if they exist.
+func WithConfigFiles(paths ...string) BindOption {
+	return func(c *bindConfig) {
+		c.configFiles = append(c.configFiles, paths...)
+	}
+}
+
+// WithCompletion adds a "completion" subcommand to the root command that prints a shell completion
+// script for bash, zsh, fish or powershell.
+func WithCompletion() BindOption {
+	return func(c *bindConfig) {
+		c.completion = true
+	}
+}
+
+// WithOutput sets where Run writes help. It defaults to os.Stdout.
+func WithOutput(w io.Writer) BindOption {
+	return func(c *bindConfig) {
+		c.out = w
+	}
+}
+
+// WithErrOutput sets where Run writes warnings, such as the use of deprecated commands and options.
+// It defaults to os.Stderr.
+func WithErrOutput(w io.Writer) BindOption {
+	return func(c *bindConfig) {
+		c.errOut = w
+	}
+}
+
+// WithLazy defers binding the options of subcommands until they run, so large command trees only pay
+// for the path that is invoked. Names and help of every command are still read up front for help listings,
+// but mistakes in the options of a subcommand are only reported when it runs.
+func WithLazy() BindOption {
+	return func(c *bindConfig) {
+		c.lazy = true
+	}
+}
+
+// WithReflection binds every command through reflection, even those with bindings generated by quackgen.
+// It is useful to rule out stale generated code.
+func WithReflection() BindOption {
+	return func(c *bindConfig) {
+		c.reflectOnly = true
+	}
+}
@@ -0,0 +1,127 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orderLeaf struct{}
+
+func (orderLeaf) Run([]string) {}
+
+type dbLeaf struct {
+	_ struct{} `category:"Database"`
+}
+
+func (dbLeaf) Run([]string) {}
+
+type orderedRoot struct{}
+
+func (orderedRoot) OrderedSubCommands() []Entry {
+	return []Entry{
+		{Name: "stop", Command: &orderLeaf{}, Category: "Server"},
+		{Name: "migrate", Command: &dbLeaf{}},
+		{Name: "version", Command: &orderLeaf{}},
+		{Name: "start", Command: &orderLeaf{}, Category: "Server"},
+		{Name: "backup", Command: &dbLeaf{}, Category: "Maintenance"},
+	}
+}
+
+type mapRoot struct{}
+
+func (mapRoot) SubCommands() Map {
+	return Map{"c": &orderLeaf{}, "a": &orderLeaf{}, "d": &orderLeaf{}, "b": &orderLeaf{}}
+}
+
+func names(c *node) []string {
+	var names []string
+	for _, s := range c.subcommands {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestSubCommandOrder(t *testing.T) {
+	rn, err := bind("app", &mapRoot{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, names(rn))
+
+	rn, err = bind("app", &orderedRoot{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stop", "migrate", "version", "start", "backup"}, names(rn))
+	var categories []string
+	for _, s := range rn.subcommands {
+		categories = append(categories, s.category)
+	}
+	assert.Equal(t, []string{"Server", "Database", "", "Server", "Maintenance"}, categories)
+}
+
+type duplicateRoot struct{}
+
+func (duplicateRoot) OrderedSubCommands() []Entry {
+	return []Entry{{Name: "a", Command: &orderLeaf{}}, {Name: "a", Command: &orderLeaf{}}}
+}
+
+func TestSubCommandDuplicate(t *testing.T) {
+	_, err := bind("app", &duplicateRoot{}, nil)
+	assert.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "app: duplicate command a")
+}
+
+// orderHelp returns the help of orderedRoot as shown by the native and flag backends.
+func orderHelp(t *testing.T) map[string]string {
+	help := map[string]string{}
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &orderedRoot{}, nil, WithOutput(&out)))
+	help["native"] = out.String()
+
+	out.Reset()
+	fs, _, err := BindFlagSet("app", &orderedRoot{})
+	assert.NoError(t, err)
+	fs.SetOutput(&out)
+	assert.ErrorIs(t, fs.Parse([]string{"-h"}), flag.ErrHelp)
+	help["flag"] = out.String()
+	return help
+}
+
+// helpOrder returns the commands and categories of orderedRoot in the order help shows them.
+func helpOrder(help string) (order, categories []string) {
+	for _, c := range regexp.MustCompile(`(?m)^ +(stop|migrate|version|start|backup) `).FindAllStringSubmatch(help, -1) {
+		order = append(order, c[1])
+	}
+	for _, s := range regexp.MustCompile(`(?m)^ *(Server|Database|Maintenance):`).FindAllStringSubmatch(help, -1) {
+		categories = append(categories, s[1])
+	}
+	return order, categories
+}
+
+func TestCategories(t *testing.T) {
+	help := orderHelp(t)
+	assert.Contains(t, regexp.MustCompile(` +\n`).ReplaceAllString(help["native"], "\n"), `
+Commands:
+  version
+
+Server:
+  stop
+  start
+
+Database:
+  migrate
+
+Maintenance:
+  backup
+`)
+
+	for name, help := range help {
+		t.Run(name, func(t *testing.T) {
+			order, categories := helpOrder(help)
+			assert.Equal(t, []string{"version", "stop", "start", "migrate", "backup"}, order)
+			assert.Equal(t, []string{"Server", "Database", "Maintenance"}, categories)
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ExistingDirPath is the path of a directory that must exist.
+type ExistingDirPath string
+
+func (d ExistingDirPath) Validate() error {
+	st, err := os.Stat(string(d))
+	if err != nil {
+		return fmt.Errorf("%w not able to find directory %s", err, d)
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", d)
+	}
+	return nil
+}
+
+// Open opens the directory for reading.
+func (d ExistingDirPath) Open() (*os.File, error) {
+	return os.Open(string(d))
+}
+
+// ReadDir lists the entries of the directory, sorted by name.
+func (d ExistingDirPath) ReadDir() ([]os.DirEntry, error) {
+	return os.ReadDir(string(d))
+}
+
+// FS returns the files under the directory as an fs.FS.
+func (d ExistingDirPath) FS() fs.FS {
+	return os.DirFS(string(d))
+}
+
+// Complete suggests directories matching partial.
+func (d ExistingDirPath) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{dirsOnly: true}.Complete(ctx, partial)
+}
+
+// NewFilePath is the path of a file to create. Its directory must exist, and the file itself must not,
+// unless the bool option named by the `overwrite` tag is set, such as --force.
+type NewFilePath string
+
+// Validate checks the directory the file is created in. Quack also checks that the file doesn't exist yet,
+// following the `overwrite` tag of the option.
+func (n NewFilePath) Validate() error {
+	if n == "" {
+		return errors.New("no file path given")
+	}
+	dir := filepath.Dir(string(n))
+	st, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%w not able to find directory %s", err, dir)
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if st, err := os.Stat(string(n)); err == nil && st.IsDir() {
+		return fmt.Errorf("%s is a directory. expected to be file", n)
+	}
+	return nil
+}
+
+// Create creates the file, or truncates it if it was allowed to exist.
+func (n NewFilePath) Create() (*os.File, error) {
+	return os.Create(string(n))
+}
+
+// OpenWith opens the file with the given flags, see os.OpenFile.
+func (n NewFilePath) OpenWith(flags int, mode os.FileMode) (*os.File, error) {
+	return os.OpenFile(string(n), flags, mode)
+}
+
+func (n NewFilePath) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{}.Complete(ctx, partial)
+}
+
+// WritablePath is the path of a file that can be written: an existing file open for writing,
+// or a new file in a writable directory.
+type WritablePath string
+
+func (w WritablePath) Validate() error {
+	if w == "" {
+		return errors.New("no file path given")
+	}
+	st, err := os.Stat(string(w))
+	switch {
+	case err == nil && st.IsDir():
+		return fmt.Errorf("%s is a directory. expected to be file", w)
+	case err == nil:
+		f, err := os.OpenFile(string(w), os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %w", w, err)
+		}
+		return f.Close()
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	// the directory is writable if a file can be created in it
+	dir := filepath.Dir(string(w))
+	probe, err := os.CreateTemp(dir, ".quack-*")
+	if err != nil {
+		return fmt.Errorf("can't create %s: %w", w, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// Create creates the file, or truncates it if it exists.
+func (w WritablePath) Create() (*os.File, error) {
+	return os.Create(string(w))
+}
+
+// Append opens the file for appending, creating it if needed.
+func (w WritablePath) Append() (*os.File, error) {
+	return os.OpenFile(string(w), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+}
+
+// OpenWith opens the file with the given flags, see os.OpenFile.
+func (w WritablePath) OpenWith(flags int, mode os.FileMode) (*os.File, error) {
+	return os.OpenFile(string(w), flags, mode)
+}
+
+func (w WritablePath) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{}.Complete(ctx, partial)
+}
+
+// Glob is a pattern, as matched by filepath.Glob, expanded to the files it matches.
+// The `min` tag sets how many files it must match.
+type Glob struct {
+	Pattern string
+	Matches []string
+}
+
+func (g *Glob) Set(pattern string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	g.Pattern, g.Matches = pattern, matches
+	return nil
+}
+
+func (g Glob) String() string { return g.Pattern }
+func (g Glob) Type() string   { return "glob" }
+
+// Open opens every file the pattern matched. If one of them fails to open, the files already opened are closed.
+func (g Glob) Open() ([]*os.File, error) {
+	files := make([]*os.File, 0, len(g.Matches))
+	for _, m := range g.Matches {
+		f, err := os.Open(m)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func (g Glob) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{}.Complete(ctx, partial)
+}
+
+// PathWithin is a path that must stay inside the directory of the `base` tag, the working directory by
+// default. Relative paths are taken from the base, and paths leaving it, through .. or symlinks, are rejected.
+// With a base, the path is made absolute so it can be used from any directory.
+type PathWithin string
+
+// Open opens the file for reading.
+func (p PathWithin) Open() (*os.File, error) {
+	return os.Open(string(p))
+}
+
+// OpenWith opens the file with the given flags, see os.OpenFile.
+func (p PathWithin) OpenWith(flags int, mode os.FileMode) (*os.File, error) {
+	return os.OpenFile(string(p), flags, mode)
+}
+
+func (p PathWithin) Complete(ctx context.Context, partial string) []string {
+	return pathCompleter{}.Complete(ctx, partial)
+}
+
+var (
+	globType       = reflect.TypeOf(Glob{})
+	pathWithinType = reflect.TypeOf(PathWithin(""))
+	newFileType    = reflect.TypeOf(NewFilePath(""))
+)
+
+// within resolves path against base, and reports an error if the result is outside of base.
+// Paths are returned as given without a base, and absolute otherwise.
+func within(base, path string) (string, error) {
+	name := base
+	if base == "" {
+		base, name = ".", "the working directory"
+	}
+	resolved := path
+	if !filepath.IsAbs(path) {
+		resolved = filepath.Join(base, path)
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+	if name == base {
+		resolved = abs
+	}
+	// symlinks are followed as far as the path exists
+	if real, err := filepath.EvalSymlinks(absBase); err == nil {
+		absBase = real
+	}
+	if real, err := evalExisting(abs); err == nil {
+		abs = real
+	}
+	if rel, err := filepath.Rel(absBase, abs); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is outside of %s", path, name)
+	}
+	return resolved, nil
+}
+
+// evalExisting resolves the symlinks of the longest part of path that exists.
+func evalExisting(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return real, err
+	}
+	dir, file := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	if dir == path {
+		return "", err
+	}
+	realDir, err := evalExisting(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realDir, file), nil
+}
+
+// checkPath follows the `min` and `base` tags of the option for a parsed Glob or PathWithin.
+func (o *option) checkPath(v reflect.Value) error {
+	switch v.Type() {
+	case globType:
+		g := v.Interface().(Glob)
+		if len(g.Matches) < o.Min {
+			return fmt.Errorf("%q matches %d files, expected at least %d", g.Pattern, len(g.Matches), o.Min)
+		}
+	case pathWithinType:
+		resolved, err := within(o.Base, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(resolved)
+	}
+	return nil
+}
+
+// checkNewFiles reports new file options naming files that already exist, unless the option
+// named by their `overwrite` tag is set.
+func (c *node) checkNewFiles() error {
+	for _, o := range append(c.flagOptions(), c.positionals()...) {
+		if o.elemType() != newFileType {
+			continue
+		}
+		if force := c.lookupOption(o.Overwrite); o.Overwrite != "" && force != nil && force.Target.Bool() {
+			continue
+		}
+		paths := []reflect.Value{o.Target}
+		if isSlice(o.Target) {
+			paths = paths[:0]
+			for i := range o.Target.Len() {
+				paths = append(paths, o.Target.Index(i))
+			}
+		}
+		for _, p := range paths {
+			if p.String() == "" {
+				continue
+			}
+			if _, err := os.Lstat(p.String()); err == nil {
+				if o.Overwrite != "" {
+					return fmt.Errorf("%s already exists, pass --%s to overwrite it", p.String(), o.Overwrite)
+				}
+				return fmt.Errorf("%s already exists", p.String())
+			}
+		}
+	}
+	return nil
+}
+
+// positionals returns the positional arguments of c that aren't ignored.
+func (c *node) positionals() []*option {
+	var options []*option
+	for i := range c.positionalOptions {
+		if !c.positionalOptions[i].Ignore {
+			options = append(options, &c.positionalOptions[i])
+		}
+	}
+	return options
+}
+
+// pathCompleter suggests files and directories matching partial, relative to base if it is set.
+// Directories end with a separator so the user can keep completing inside them.
+type pathCompleter struct {
+	base     string
+	dirsOnly bool
+}
+
+// The directory is listed rather than globbed, so names with *, ? or [ complete like any other.
+func (p pathCompleter) Complete(ctx context.Context, partial string) []string {
+	dir, name := filepath.Split(partial)
+	list := dir
+	if p.base != "" && !filepath.IsAbs(partial) {
+		list = filepath.Join(p.base, dir)
+	}
+	if list == "" {
+		list = "."
+	}
+	entries, _ := os.ReadDir(list)
+	suggestions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), name) {
+			continue
+		}
+		// symlinks to directories are completed as directories
+		st, err := os.Stat(filepath.Join(list, e.Name()))
+		isDir := err == nil && st.IsDir()
+		if p.dirsOnly && !isDir {
+			continue
+		}
+		s := dir + e.Name()
+		if isDir {
+			s += string(filepath.Separator)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions
+}
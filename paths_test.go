@@ -0,0 +1,174 @@
+package quack
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type convertCmd struct {
+	Force   bool         `short:"f"`
+	Out     NewFilePath  `short:"o" overwrite:"force"`
+	Log     WritablePath `default:"convert.log"`
+	Inputs  Glob         `min:"2"`
+	Extra   []Glob
+	Assets  ExistingDirPath
+	Include PathWithin `base:"testdata-base"`
+	Config  PathWithin `arg:"1"`
+}
+
+func (c *convertCmd) Run([]string) {}
+
+// fileTree creates files under a temporary directory, makes it the working directory and returns it.
+func fileTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(f), 0o644))
+	}
+	t.Chdir(dir)
+	return dir
+}
+
+func TestPaths(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			dir := fileTree(t, "a.csv", "b.csv", "c.txt", "assets/logo.png", "testdata-base/inc/x.h", "app.toml", "old.json")
+			// flags are followed by the positional argument, since the flag package stops at the first one
+			args := func(extra ...string) []string {
+				return append(append([]string{"--inputs", "*.csv", "--assets", "assets", "-o", "new.json"}, extra...), "app.toml")
+			}
+
+			cmd := &convertCmd{}
+			assert.NoError(t, run(t, cmd, args("--extra", "*.txt", "--extra", "assets/*", "--include", "inc/x.h")...))
+			assert.Equal(t, NewFilePath("new.json"), cmd.Out)
+			assert.Equal(t, WritablePath("convert.log"), cmd.Log)
+			assert.Equal(t, Glob{Pattern: "*.csv", Matches: []string{"a.csv", "b.csv"}}, cmd.Inputs)
+			assert.Equal(t, []Glob{
+				{Pattern: "*.txt", Matches: []string{"c.txt"}},
+				{Pattern: "assets/*", Matches: []string{filepath.Join("assets", "logo.png")}},
+			}, cmd.Extra)
+			assert.Equal(t, ExistingDirPath("assets"), cmd.Assets)
+			assert.Equal(t, PathWithin(filepath.Join(dir, "testdata-base", "inc", "x.h")), cmd.Include)
+			assert.Equal(t, PathWithin("app.toml"), cmd.Config)
+
+			assert.NoError(t, run(t, &convertCmd{}, args("-o", "old.json", "--force")...))
+
+			for _, tt := range []struct {
+				args []string
+				err  string
+			}{
+				{[]string{"-o", "old.json"}, "old.json already exists, pass --force to overwrite it"},
+				{[]string{"-o", "missing/new.json"}, "not able to find directory missing"},
+				{[]string{"-o", "assets"}, "assets already exists"},
+				{[]string{"--inputs", "*.txt"}, `"*.txt" matches 1 files, expected at least 2`},
+				{[]string{"--inputs", "[a-"}, `invalid pattern "[a-"`},
+				{[]string{"--assets", "a.csv"}, "a.csv is not a directory"},
+				{[]string{"--assets", "nope"}, "not able to find directory nope"},
+				{[]string{"--log", "assets"}, "assets is a directory"},
+				{[]string{"--log", "missing/x.log"}, "can't create missing/x.log"},
+				{[]string{"--include", "../a.csv"}, "../a.csv is outside of testdata-base"},
+				{[]string{"--include", dir}, dir + " is outside of testdata-base"},
+			} {
+				assert.ErrorContains(t, run(t, &convertCmd{}, args(tt.args...)...), tt.err, tt.args)
+			}
+			assert.ErrorContains(t, run(t, &convertCmd{}, "--inputs", "*.csv", "-o", "new.json", "../escape"),
+				"../escape is outside of the working directory")
+		})
+	}
+}
+
+func TestPathWithinSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	dir := fileTree(t, "base/file", "outside/secret")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dir, "base", "link")))
+
+	_, err := within("base", "link/secret")
+	assert.ErrorContains(t, err, "link/secret is outside of base")
+	_, err = within("base", "link/new")
+	assert.ErrorContains(t, err, "link/new is outside of base")
+	path, err := within("base", "sub/../file")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "base", "file"), path)
+}
+
+func TestPathsInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Out    NewFilePath `overwrite:"yes"`
+		Dest   NewFilePath `overwrite:"out"`
+		Files  Glob        `min:"0"`
+		Inputs []string    `min:"1"`
+		Root   string      `base:"/srv"`
+	}{}, nil)
+	require.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, `overwrite of out must name a bool option, got "yes"`)
+	assert.ErrorContains(t, err, `overwrite of dest must name a bool option, got "out"`)
+	assert.ErrorContains(t, err, "invalid min value '0' for field Files: must be a positive integer")
+	assert.ErrorContains(t, err, "min of inputs needs a quack.Glob, got []string")
+	assert.ErrorContains(t, err, "base of root needs a quack.PathWithin, got string")
+}
+
+func TestPathOpen(t *testing.T) {
+	fileTree(t, "in/a.txt", "in/b.txt")
+
+	files, err := Glob{Matches: []string{"in/a.txt", "in/b.txt"}}.Open()
+	require.NoError(t, err)
+	for _, f := range files {
+		b, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, f.Name(), string(b))
+		f.Close()
+	}
+	_, err = Glob{Matches: []string{"in/a.txt", "in/missing.txt"}}.Open()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	entries, err := ExistingDirPath("in").ReadDir()
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	f, err := NewFilePath("in/c.txt").Create()
+	require.NoError(t, err)
+	f.Close()
+	w, err := WritablePath("in/c.txt").Append()
+	require.NoError(t, err)
+	_, err = w.WriteString("more")
+	assert.NoError(t, err)
+	w.Close()
+	b, err := os.ReadFile("in/c.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "more", string(b))
+
+	_, err = PathWithin("in/missing").Open()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = ExistingFilePath("in/missing").OpenE()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPathComplete(t *testing.T) {
+	fileTree(t, "src/main.go", "src/util/x.go", "sdk.txt", "testdata-base/inc/y.h", "[draft]*.md", "draft?.md")
+	ctx := context.Background()
+	sep := string(filepath.Separator)
+	assert.Equal(t, []string{"sdk.txt", "src" + sep}, WritablePath("").Complete(ctx, "s"))
+	assert.Equal(t, []string{"src" + sep}, ExistingDirPath("").Complete(ctx, "s"))
+	assert.Equal(t, []string{filepath.Join("src", "util") + sep}, ExistingDirPath("").Complete(ctx, "src/"))
+	// partial input is a prefix, not a pattern
+	assert.Equal(t, []string{"[draft]*.md"}, WritablePath("").Complete(ctx, "[d"))
+	assert.Equal(t, []string{"draft?.md"}, WritablePath("").Complete(ctx, "draft?"))
+	assert.Empty(t, WritablePath("").Complete(ctx, "*"))
+
+	// paths within a base are completed from it
+	n, err := bind("app", &convertCmd{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inc" + sep}, n.lookupOption("include").completer().Complete(ctx, "i"))
+}
@@ -0,0 +1,90 @@
+package quack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pointerCmd struct {
+	Retries *int `short:"r" env:"POINTER_TEST_RETRIES"`
+	Name    *string
+	Force   *bool          `short:"f"`
+	Wait    *time.Duration `required:""`
+	Since   *version
+	Check   *validatingOption
+	Target  *string `arg:"1"`
+}
+
+func (p *pointerCmd) Run([]string) {}
+
+func TestPointers(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &pointerCmd{}
+			assert.NoError(t, run(t, cmd, "--wait", "1s"))
+			assert.Equal(t, time.Second, *cmd.Wait)
+			assert.Nil(t, cmd.Retries)
+			assert.Nil(t, cmd.Name)
+			assert.Nil(t, cmd.Force)
+			assert.Nil(t, cmd.Since)
+			assert.Nil(t, cmd.Target)
+
+			cmd = &pointerCmd{}
+			assert.NoError(t, run(t, cmd, "-r", "0", "--name", "", "-f=false", "--wait", "0s", "--since", "v1.2", "here"))
+			require.NotNil(t, cmd.Retries)
+			assert.Equal(t, 0, *cmd.Retries)
+			assert.Equal(t, "", *cmd.Name)
+			assert.False(t, *cmd.Force)
+			assert.Equal(t, time.Duration(0), *cmd.Wait)
+			assert.Equal(t, version{1, 2}, *cmd.Since)
+			assert.Equal(t, "here", *cmd.Target)
+
+			cmd = &pointerCmd{}
+			assert.NoError(t, run(t, cmd, "--no-force", "--wait", "1s"))
+			assert.False(t, *cmd.Force)
+
+			assert.ErrorContains(t, run(t, &pointerCmd{}), "wait")
+			assert.ErrorContains(t, run(t, &pointerCmd{}, "--wait", "1s", "--check", "invalid"), "option value is invalid")
+		})
+	}
+}
+
+func TestPointersEnvAndConfig(t *testing.T) {
+	t.Setenv("POINTER_TEST_RETRIES", "0")
+	path := writeConfig(t, t.TempDir(), "app.json", `{"force": false, "wait": "2s"}`)
+	for name, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run(name, func(t *testing.T) {
+			cmd := &pointerCmd{}
+			assert.NoError(t, run(t, cmd))
+			assert.Equal(t, 0, *cmd.Retries)
+			assert.False(t, *cmd.Force)
+			assert.Equal(t, 2*time.Second, *cmd.Wait)
+			assert.Nil(t, cmd.Name)
+		})
+	}
+}
+
+func TestPointersPreset(t *testing.T) {
+	retries := 3
+	cmd := &pointerCmd{Retries: &retries}
+	assert.NoError(t, backends["native"](t, cmd, "--wait", "1s"))
+	assert.Same(t, &retries, cmd.Retries)
+
+	assert.NoError(t, backends["native"](t, cmd, "--wait", "1s", "-r", "5"))
+	assert.Equal(t, 5, *cmd.Retries)
+	assert.Equal(t, 3, retries)
+}
+
+func TestPointersInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Retries *int `default:"3"`
+		Tags    *[]string
+	}{}, nil)
+	require.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "pointer option retries can't have a default, it is nil until set")
+	assert.ErrorContains(t, err, "pointer option tags must point to a single value, got *[]string")
+}
@@ -0,0 +1,283 @@
+// Package quackgen writes part of the bindings of quack commands ahead of time, so options of builtin types
+// are registered and parsed through typed pointers rather than unsafe casts, and validators are called
+// directly. It doesn't generate the rest of binding: quack still reflects over the struct for its tags,
+// defaults, help and option groups, and still registers the flags and parses positional arguments itself.
+//
+// For each command it generates the methods of quack.Generated: QuackFields, returning a typed pointer to
+// the field of every option, and QuackValidate, calling the validators of the options and option groups.
+// Fields are walked the same way quack walks them when binding, so the generated code behaves exactly
+// like the reflection it replaces. Fields that receive the globals of an ancestor can't be told apart
+// from option groups here, and are treated like them.
+package quackgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eliothedeman/quack/internal/gotypes"
+	"github.com/iancoleman/strcase"
+	"golang.org/x/tools/go/packages"
+)
+
+// field is an option of a command.
+type field struct {
+	name     string // name of the option, including the prefix of its groups
+	expr     string // selector of the field, from the command
+	typ      types.Type
+	arg      int // position of a positional argument, 0 for flags
+	validate bool
+	nilable  bool // a pointer option, left nil until set and bound by quack itself
+}
+
+// group is a struct of options, embedded or named, that implements quack.Validator.
+type group struct {
+	expr string
+	name string
+}
+
+// command is a struct bound by quack.
+type command struct {
+	name       string
+	validates  bool // the command implements quack.Validator, which replaces validating its options
+	options    []field
+	positional []field
+	groups     []group
+}
+
+// Generate loads the package in dir and returns the source of a file,
+// in the same package, that implements quack.Generated for each of the named struct types.
+func Generate(dir string, typeNames []string) ([]byte, error) {
+	// type check from source rather than export data, which ties the tool to the version of Go that built it
+	mode := packages.NeedName | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo |
+		packages.NeedImports | packages.NeedDeps
+	pkgs, err := packages.Load(&packages.Config{Mode: mode, Dir: dir}, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+
+	var commands []*command
+	for _, name := range typeNames {
+		obj, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, pkg.PkgPath)
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("%s must be a named struct type without type parameters", name)
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a struct", name)
+		}
+		c := &command{name: name, validates: hasValidate(types.NewPointer(named))}
+		if err := c.walk(st, "c", ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		slices.SortStableFunc(c.positional, func(a, b field) int {
+			return a.arg - b.arg
+		})
+		commands = append(commands, c)
+	}
+	return render(pkg.Name, commands)
+}
+
+// walk collects the options of st, following the rules of node.addOptions.
+func (c *command) walk(st *types.Struct, expr, prefix string) error {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		_, ignore := tag.Lookup("ignore")
+		_, isArg := tag.Lookup("arg")
+		inner, isGroup := gotypes.GroupStruct(f.Type(), isArg)
+		if ignore && (f.Embedded() || isGroup) {
+			continue
+		}
+		sel := expr + "." + f.Name()
+
+		if f.Embedded() && isGroup {
+			if err := c.walk(inner, sel, prefix); err != nil {
+				return err
+			}
+			c.addGroup(sel, f.Type())
+			continue
+		}
+		if f.Embedded() || !f.Exported() {
+			continue
+		}
+		if isGroup {
+			groupPrefix, ok := tag.Lookup("prefix")
+			if !ok {
+				groupPrefix = strcase.ToKebab(f.Name())
+			}
+			if groupPrefix != "" {
+				groupPrefix = prefix + groupPrefix + "-"
+			} else {
+				groupPrefix = prefix
+			}
+			if err := c.walk(inner, sel, groupPrefix); err != nil {
+				return err
+			}
+			c.addGroup(sel, f.Type())
+			continue
+		}
+		// ignored options are neither registered nor validated
+		if ignore {
+			continue
+		}
+		// pointers to structs receive the globals of an ancestor
+		p, nilable := f.Type().Underlying().(*types.Pointer)
+		if nilable && !gotypes.IsCustom(f.Type()) && !gotypes.IsCustom(p.Elem()) {
+			if _, ok := p.Elem().Underlying().(*types.Struct); ok {
+				continue
+			}
+		}
+
+		opt := field{
+			name:     prefix + strcase.ToKebab(f.Name()),
+			expr:     sel,
+			typ:      f.Type(),
+			validate: hasValidate(f.Type()),
+			nilable:  nilable && !gotypes.IsCustom(f.Type()),
+		}
+		if a := tag.Get("arg"); a != "" {
+			arg, err := strconv.Atoi(a)
+			if err != nil || arg < 1 {
+				return fmt.Errorf("invalid arg value '%s' for field %s: must be a positive integer", a, f.Name())
+			}
+			opt.arg = arg
+			c.positional = append(c.positional, opt)
+		} else {
+			c.options = append(c.options, opt)
+		}
+	}
+	return nil
+}
+
+// all returns the named options of c followed by its positional arguments, in the order quack validates them.
+func (c *command) all() []field {
+	return slices.Concat(c.options, c.positional)
+}
+
+func (c *command) addGroup(expr string, t types.Type) {
+	if !hasValidate(types.NewPointer(t)) {
+		return
+	}
+	name := ""
+	if named, ok := t.(*types.Named); ok {
+		name = named.Obj().Name()
+	}
+	c.groups = append(c.groups, group{expr: expr, name: name})
+}
+
+// hasValidate reports if t implements quack.Validator.
+func hasValidate(t types.Type) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, "Validate")
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 1 &&
+		types.Identical(sig.Results().At(0).Type(), types.Universe.Lookup("error").Type())
+}
+
+// pointer is the expression of a pointer to the option's field. Named types are converted to pointers to
+// their underlying builtin type, which quack registers and parses without reflection.
+func (f field) pointer() string {
+	ptr := "&" + f.expr
+	if gotypes.IsCustom(f.typ) {
+		return ptr
+	}
+	if isDuration(f.typ) {
+		return ptr
+	}
+	u := f.typ.Underlying()
+	if types.Identical(f.typ, u) || !builtin(u) {
+		return ptr
+	}
+	return fmt.Sprintf("(*%s)(%s)", types.TypeString(u, nil), ptr)
+}
+
+func isDuration(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration"
+}
+
+// builtin reports if t is a basic type, or a slice of one, that can be spelled without an import.
+func builtin(t types.Type) bool {
+	if s, ok := t.(*types.Slice); ok {
+		t = s.Elem()
+	}
+	_, ok := t.(*types.Basic)
+	return ok
+}
+
+func render(pkg string, commands []*command) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by quackgen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	needsFmt := slices.ContainsFunc(commands, func(c *command) bool {
+		return !c.validates && (len(c.groups) > 0 ||
+			slices.ContainsFunc(c.all(), func(f field) bool { return f.validate }))
+	})
+	if needsFmt {
+		b.WriteString("import \"fmt\"\n\n")
+	}
+
+	for _, c := range commands {
+		fmt.Fprintf(&b, "// QuackFields returns a pointer to the field of each option of %s.\n", c.name)
+		fmt.Fprintf(&b, "func (c *%s) QuackFields() map[string]any {\n\treturn map[string]any{\n", c.name)
+		seen := map[string]bool{}
+		for _, f := range c.all() {
+			// duplicate names are reported by quack when binding, and pointer options are bound through reflection
+			if seen[f.name] || f.nilable {
+				continue
+			}
+			seen[f.name] = true
+			fmt.Fprintf(&b, "\t\t%q: %s,\n", f.name, f.pointer())
+		}
+		b.WriteString("\t}\n}\n\n")
+
+		fmt.Fprintf(&b, "// QuackValidate validates the options and option groups of %s.\n", c.name)
+		fmt.Fprintf(&b, "func (c *%s) QuackValidate() error {\n", c.name)
+		if c.validates {
+			fmt.Fprintf(&b, "\t// %s validates itself\n", c.name)
+		} else {
+			for _, f := range c.all() {
+				if f.validate && f.nilable {
+					fmt.Fprintf(&b, "\tif %s != nil {\n", f.expr)
+					writeValidate(&b, f.expr, "option "+f.name)
+					b.WriteString("\t}\n")
+				} else if f.validate {
+					writeValidate(&b, f.expr, "option "+f.name)
+				}
+			}
+			for _, g := range c.groups {
+				writeValidate(&b, g.expr, g.name)
+			}
+		}
+		b.WriteString("\treturn nil\n}\n\n")
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+func writeValidate(b *bytes.Buffer, expr, what string) {
+	fmt.Fprintf(b, "\tif err := %s.Validate(); err != nil {\n", expr)
+	fmt.Fprintf(b, "\t\treturn fmt.Errorf(\"validation failed for %s: %%w\", err)\n\t}\n", strings.ReplaceAll(what, "%", "%%"))
+}
@@ -0,0 +1,33 @@
+package quackgen_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/eliothedeman/quack/quackgen"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGenerateUpToDate regenerates the bindings of the commands in tests/generated,
+// which are checked against reflection by that package's tests.
+func TestGenerateUpToDate(t *testing.T) {
+	want, err := os.ReadFile("../tests/generated/root_cmd_quack.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := quackgen.Generate("../tests/generated", []string{"rootCmd", "serveCmd", "globals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(want), string(got), "run go generate ./tests/generated")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for name, want := range map[string]string{
+		"missing": "type missing not found in github.com/eliothedeman/quack/tests/generated",
+		"Level":   "Level is not a struct",
+	} {
+		_, err := quackgen.Generate("../tests/generated", []string{name})
+		assert.EqualError(t, err, want)
+	}
+}
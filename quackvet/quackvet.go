@@ -0,0 +1,517 @@
+// Package quackvet defines an analyzer that finds mistakes in the structs bound by quack,
+// which would otherwise only show up when the binary runs.
+//
+// It checks the roots passed to the bind functions and the commands listed in quack.Map and quack.Entry
+// literals for unknown struct tags, invalid arg positions, short names and defaults, conflicting flag names,
+// and Run methods whose signature doesn't make the struct a command.
+package quackvet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eliothedeman/quack/internal/fuzzy"
+	"github.com/eliothedeman/quack/internal/gotypes"
+	"github.com/iancoleman/strcase"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const quackPath = "github.com/eliothedeman/quack"
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "quackvet",
+	Doc:      "check structs bound by quack for invalid tags and command signatures",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// KnownTags are the struct tag keys quack reads.
+var KnownTags = []string{
+	"help", "default", "short", "long", "ignore", "prefix", "arg", "repeated",
+	"env", "required", "xor", "and", "requires", "category", "alias", "hidden", "deprecated", "enum",
+	"counter", "nonegate", "sep", "duplicates", "layout", "scheme",
+	"min", "base", "overwrite",
+}
+
+// otherTags are struct tag keys of other packages that are commonly found on option structs.
+var otherTags = []string{"json", "yaml", "toml", "xml", "mapstructure"}
+
+// bindFuncs maps the functions that bind a root command to the index of the root argument.
+var bindFuncs = map[string]int{
+	"BindCobra":      1,
+	"MustBindCobra":  1,
+	"BindUrfave":     1,
+	"MustBindUrfave": 1,
+	"BindFlagSet":    1,
+	"Run":            2,
+}
+
+// commandMethods are the signatures of the methods that make a struct a command.
+var commandMethods = map[string][]string{
+	"RunE":               {"(context.Context, []string) error"},
+	"RunContext":         {"(context.Context) error"},
+	"SubCommands":        {"() " + quackPath + ".Map"},
+	"OrderedSubCommands": {"() []" + quackPath + ".Entry"},
+	"Run": {
+		"([]string)",
+		"()",
+		"(*github.com/spf13/cobra.Command, []string)",
+		"(context.Context, *github.com/urfave/cli/v3.Command) error",
+	},
+}
+
+type checker struct {
+	pass      *analysis.Pass
+	mapType   types.Type
+	entryType types.Type
+	checked   map[*types.Named]bool
+	reported  map[string]bool
+}
+
+// report is pass.Reportf, without repeating diagnostics for types shared by several commands.
+func (c *checker) report(pos token.Pos, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	key := fmt.Sprint(pos, msg)
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+	c.pass.Report(analysis.Diagnostic{Pos: pos, Message: msg})
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	var quack *types.Package
+	for _, p := range pass.Pkg.Imports() {
+		if p.Path() == quackPath {
+			quack = p
+		}
+	}
+	if quack == nil {
+		return nil, nil
+	}
+	c := &checker{pass: pass, checked: map[*types.Named]bool{}, reported: map[string]bool{}}
+	if m := quack.Scope().Lookup("Map"); m != nil {
+		c.mapType = m.Type()
+	}
+	if e := quack.Scope().Lookup("Entry"); e != nil {
+		c.entryType = e.Type()
+	}
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodes := []ast.Node{(*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}
+	insp.Preorder(nodes, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			fn, ok := typeutil.Callee(pass.TypesInfo, n).(*types.Func)
+			if !ok || fn.Pkg() == nil || fn.Pkg().Path() != quackPath {
+				return
+			}
+			if i, ok := bindFuncs[fn.Name()]; ok && i < len(n.Args) {
+				c.checkCommand(n.Args[i])
+			}
+		case *ast.CompositeLit:
+			t := pass.TypesInfo.TypeOf(n)
+			switch {
+			case c.mapType != nil && types.Identical(t, c.mapType):
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						c.checkCommand(kv.Value)
+					}
+				}
+			case c.entryType != nil && types.Identical(t, c.entryType):
+				for i, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if id, ok := kv.Key.(*ast.Ident); ok && id.Name == "Command" {
+							c.checkCommand(kv.Value)
+						}
+					} else if i == 1 {
+						c.checkCommand(elt)
+					}
+				}
+			}
+		}
+	})
+	return nil, nil
+}
+
+// checkCommand reports if the value of expr can't be bound as a command, then checks its fields.
+func (c *checker) checkCommand(expr ast.Expr) {
+	t := c.pass.TypesInfo.TypeOf(expr)
+	if t == nil || (c.mapType != nil && types.Identical(t, c.mapType)) {
+		return
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		// only known at runtime
+		return
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		c.report(expr.Pos(), "%s can't be a quack command: only structs can be commands", named.Obj().Name())
+		return
+	}
+
+	if msg := commandProblem(named); msg != "" {
+		c.report(expr.Pos(), "%s is not a quack command: %s", named.Obj().Name(), msg)
+	}
+	if !c.checked[named] && named.Obj().Pkg() == c.pass.Pkg {
+		c.checked[named] = true
+		c.checkStruct(named)
+	}
+}
+
+// commandProblem explains why named doesn't implement any of the command interfaces, or returns "".
+func commandProblem(named *types.Named) string {
+	methods := types.NewMethodSet(types.NewPointer(named))
+	var wrong []string
+	for _, name := range sortedKeys(commandMethods) {
+		sel := methods.Lookup(nil, name)
+		if sel == nil {
+			continue
+		}
+		sig := signature(sel.Obj().Type().(*types.Signature))
+		for _, want := range commandMethods[name] {
+			if sig == want {
+				return ""
+			}
+		}
+		wrong = append(wrong, name+sig)
+	}
+	if len(wrong) > 0 {
+		return fmt.Sprintf("%s doesn't match any command signature", strings.Join(wrong, ", "))
+	}
+	return "it has no Run, RunE, RunContext, SubCommands or OrderedSubCommands method"
+}
+
+// signature renders the parameter and result types of sig, without names.
+func signature(sig *types.Signature) string {
+	var params, results []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i).Type().String())
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, sig.Results().At(i).Type().String())
+	}
+	s := "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
+
+// names tracks the flags and positions of a command, to report conflicts.
+type names struct {
+	longs  map[string]string
+	shorts map[string]string
+	args   map[int]string
+}
+
+// checkStruct checks the fields of a command, the same way quack walks them when binding.
+func (c *checker) checkStruct(named *types.Named) {
+	n := &names{
+		longs:  map[string]string{"help": "help"},
+		shorts: map[string]string{"h": "help"},
+		args:   map[int]string{},
+	}
+	c.checkFields(named.Underlying().(*types.Struct), "", n, token.NoPos)
+}
+
+// checkFields checks the fields of st. Conflicting names in option groups are reported at the group's field,
+// at, since the conflict comes from the command using the group.
+func (c *checker) checkFields(st *types.Struct, prefix string, n *names, at token.Pos) {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		conflictPos := at
+		if at == token.NoPos {
+			conflictPos = f.Pos()
+		}
+		tags, ok := parseTag(st.Tag(i))
+		if !ok {
+			c.report(f.Pos(), "malformed struct tag on %s", f.Name())
+			continue
+		}
+		for _, key := range sortedKeys(tags) {
+			if !contains(KnownTags, key) && !contains(otherTags, key) {
+				c.report(f.Pos(), "unknown struct tag %q on %s%s", key, f.Name(), suggest(key))
+			}
+		}
+		if _, ignore := tags["ignore"]; ignore {
+			continue
+		}
+
+		_, isArg := tags["arg"]
+		if inner, ok := gotypes.GroupStruct(f.Type(), isArg); ok {
+			if f.Embedded() {
+				c.checkFields(inner, prefix, n, conflictPos)
+				continue
+			}
+			if !f.Exported() {
+				continue
+			}
+			groupPrefix, ok := tags["prefix"]
+			if !ok {
+				groupPrefix = strcase.ToKebab(f.Name())
+			}
+			if groupPrefix != "" {
+				groupPrefix = prefix + groupPrefix + "-"
+			} else {
+				groupPrefix = prefix
+			}
+			c.checkFields(inner, groupPrefix, n, conflictPos)
+			continue
+		}
+		if f.Embedded() || !f.Exported() {
+			continue
+		}
+
+		name := prefix + strcase.ToKebab(f.Name())
+		// pointer options are parsed as the type they point to
+		typ := f.Type()
+		p, isPointer := typ.Underlying().(*types.Pointer)
+		if isPointer && !gotypes.IsCustom(typ) {
+			typ = p.Elem()
+		} else {
+			isPointer = false
+		}
+		if _, ok := tags["default"]; ok && isPointer {
+			c.report(f.Pos(), "pointer option %s can't have a default, it is nil until set", f.Name())
+		} else if def, ok := tags["default"]; ok {
+			if err := checkDefault(typ, def, tags["sep"]); err != nil {
+				c.report(f.Pos(), "invalid default %q for %s: %v", def, f.Name(), err)
+			}
+		}
+		if choices := splitList(tags["enum"]); len(choices) > 0 {
+			for _, def := range splitList(tags["default"]) {
+				if !contains(choices, def) {
+					c.report(f.Pos(), "default %q of %s is not one of %s", def, f.Name(), strings.Join(choices, ", "))
+				}
+			}
+		}
+		if m, ok := tags["min"]; ok {
+			if n, err := strconv.Atoi(m); err != nil || n < 1 {
+				c.report(f.Pos(), "min %q of %s must be a positive integer", m, f.Name())
+			}
+		}
+		if s, ok := tags["short"]; ok && utf8.RuneCountInString(s) != 1 {
+			c.report(f.Pos(), "short name %q of %s must be a single character", s, f.Name())
+		}
+
+		if a, ok := tags["arg"]; ok {
+			pos, err := strconv.Atoi(a)
+			if err != nil || pos < 1 {
+				c.report(f.Pos(), "arg %q of %s must be a positive integer", a, f.Name())
+				continue
+			}
+			if other, ok := n.args[pos]; ok {
+				c.report(conflictPos, "%s and %s are both positional argument %d", other, f.Name(), pos)
+			}
+			n.args[pos] = f.Name()
+			continue
+		}
+
+		if _, ok := tags["counter"]; ok && !types.Identical(typ, types.Typ[types.Int]) {
+			c.report(f.Pos(), "counter %s must be an int", f.Name())
+		}
+		longs := append([]string{name}, splitList(tags["alias"])...)
+		if _, ok := tags["nonegate"]; !ok && isKind(typ, types.Bool) {
+			longs = append(longs, "no-"+name)
+		}
+		for _, long := range longs {
+			if _, ok := n.longs[long]; ok {
+				c.report(conflictPos, "duplicate flag --%s", long)
+			} else if other, ok := n.shorts[long]; ok && other != name {
+				c.report(conflictPos, "flag --%s is already the short name of --%s", long, other)
+			}
+			n.longs[long] = name
+		}
+		if s := tags["short"]; s != "" {
+			if other, ok := n.shorts[s]; ok {
+				c.report(conflictPos, "short name -%s of --%s is already used by --%s", s, name, other)
+			} else if other, ok := n.longs[s]; ok && other != name {
+				c.report(conflictPos, "short name -%s of --%s is already used by --%s", s, name, other)
+			}
+			n.shorts[s] = name
+		}
+	}
+}
+
+// isKind reports if t is a basic type of the given kind, as opposed to a type parsing itself.
+func isKind(t types.Type, kind types.BasicKind) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == kind && !gotypes.IsCustom(t)
+}
+
+// checkDefault parses def the way quack would for a field of type t.
+func checkDefault(t types.Type, def, sep string) error {
+	if gotypes.IsCustom(t) {
+		return nil
+	}
+	if s, ok := t.Underlying().(*types.Slice); ok {
+		for _, elem := range strings.Split(def, ",") {
+			if err := checkDefault(s.Elem(), elem, sep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if m, ok := t.Underlying().(*types.Map); ok {
+		if sep == "" {
+			sep = "="
+		}
+		for _, entry := range strings.Split(def, ",") {
+			k, v, ok := strings.Cut(entry, sep)
+			if !ok {
+				return fmt.Errorf("expected key%svalue, got %q", sep, entry)
+			}
+			if err := checkDefault(m.Key(), k, sep); err != nil {
+				return err
+			}
+			if err := checkDefault(m.Elem(), v, sep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Duration" {
+		_, err := time.ParseDuration(def)
+		return err
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return nil
+	}
+	var err error
+	switch basic.Kind() {
+	case types.Bool:
+		_, err = strconv.ParseBool(def)
+	case types.Int, types.Int64:
+		_, err = strconv.ParseInt(def, 10, 64)
+	case types.Int8:
+		_, err = strconv.ParseInt(def, 10, 8)
+	case types.Int16:
+		_, err = strconv.ParseInt(def, 10, 16)
+	case types.Int32:
+		_, err = strconv.ParseInt(def, 10, 32)
+	case types.Uint, types.Uint64:
+		_, err = strconv.ParseUint(def, 10, 64)
+	case types.Uint8:
+		_, err = strconv.ParseUint(def, 10, 8)
+	case types.Uint16:
+		_, err = strconv.ParseUint(def, 10, 16)
+	case types.Uint32:
+		_, err = strconv.ParseUint(def, 10, 32)
+	case types.Float32:
+		_, err = strconv.ParseFloat(def, 32)
+	case types.Float64:
+		_, err = strconv.ParseFloat(def, 64)
+	}
+	if ne, ok := err.(*strconv.NumError); ok {
+		return ne.Err
+	}
+	return err
+}
+
+// parseTag splits a struct tag into its keys and values, following the conventions of reflect.StructTag.
+func parseTag(tag string) (map[string]string, bool) {
+	tags := map[string]string{}
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return nil, false
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return nil, false
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			return nil, false
+		}
+		tags[key] = value
+		tag = tag[i+1:]
+	}
+	return tags, true
+}
+
+// suggest proposes the known tag closest to key, if it looks like a typo.
+func suggest(key string) string {
+	best, dist := "", 3
+	for _, known := range KnownTags {
+		if d := fuzzy.Distance(key, known); d < dist {
+			best, dist = known, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// splitList splits a comma separated tag value like quack does, ignoring empty entries.
+func splitList(tag string) []string {
+	var out []string
+	for _, s := range strings.Split(tag, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package quackvet_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eliothedeman/quack/quackvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), quackvet.Analyzer, "a")
+}
+
+// TestKnownTags keeps the analyzer in sync with the tags quack reads.
+func TestKnownTags(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "../bind.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasSuffix(name.Name, "Tag") || i >= len(vs.Values) {
+					continue
+				}
+				lit, ok := vs.Values[i].(*ast.BasicLit)
+				if !ok {
+					continue
+				}
+				tag, _ := strconv.Unquote(lit.Value)
+				if !slices.Contains(quackvet.KnownTags, tag) {
+					t.Errorf("quackvet doesn't know the %q tag", tag)
+				}
+			}
+		}
+	}
+}
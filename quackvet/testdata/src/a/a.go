@@ -0,0 +1,125 @@
+package a
+
+import (
+	"context"
+	"time"
+
+	"github.com/eliothedeman/quack"
+	"github.com/spf13/cobra"
+)
+
+type root struct {
+	Verbose bool `short:"v"`
+}
+
+func (r *root) SubCommands() quack.Map {
+	return quack.Map{
+		"serve":  &serve{},
+		"cobra":  &cobraCmd{},
+		"broken": &broken{}, // want `broken is not a quack command: Run\(string\) doesn't match any command signature`
+		"none":   &none{},   // want `none is not a quack command: it has no Run, RunE, RunContext, SubCommands or OrderedSubCommands method`
+		"nested": quack.Map{"x": &serve{}},
+	}
+}
+
+type serve struct {
+	Port     int           `shrot:"p"` // want `unknown struct tag "shrot" on Port \(did you mean "short"\?\)`
+	Host     string        `json:"host"`
+	Timeout  time.Duration `default:"soon"` // want `invalid default "soon" for Timeout: time: invalid duration "soon"`
+	Count    int8          `default:"300"`  // want `invalid default "300" for Count: value out of range`
+	Tags     []int         `default:"1,x"`  // want `invalid default "1,x" for Tags: invalid syntax`
+	Name     string        `short:"nm"`     // want `short name "nm" of Name must be a single character`
+	Hidden   bool          `short:"h"`      // want `short name -h of --hidden is already used by --help`
+	Pass     string        `short:"p"`
+	Password string        `short:"p"` // want `short name -p of --password is already used by --pass`
+	Source   string        `arg:"0"`   // want `arg "0" of Source must be a positive integer`
+	Target   string        `arg:"1"`
+	Other    string        `arg:"1"` // want `Target and Other are both positional argument 1`
+	DB       db
+	Replica  db `prefix:"ro"`
+	Flat     db `prefix:""` // want `duplicate flag --host`
+	Skipped  db `ignore:""`
+	Dup      dup
+	Addr     string `alias:"old-addr"`
+	Bind     string `alias:"old-addr"`               // want `duplicate flag --old-addr`
+	Format   string `enum:"json,yaml" default:"xml"` // want `default "xml" of Format is not one of json, yaml`
+	Cache    bool
+	NoCache  bool // want `duplicate flag --no-cache`
+	Color    bool `nonegate:""`
+	NoColor  bool
+	Verbose  string            `counter:""`        // want `counter Verbose must be an int`
+	Labels   map[string]int    `default:"a=1,b=x"` // want `invalid default "a=1,b=x" for Labels: invalid syntax`
+	Args     map[string]string `sep:":" default:"a:1"`
+	Workers  *int              `default:"4"` // want `pointer option Workers can't have a default, it is nil until set`
+	Dry      *bool
+	NoDry    bool     // want `duplicate flag --no-dry`
+	Inputs   []string `min:"none"` // want `min "none" of Inputs must be a positive integer`
+	X        int
+	Y        int  `short:"x"` // want `short name -x of --y is already used by --x`
+	Queue    int  `short:"q"`
+	Quiet    bool `alias:"q"` // want `flag --q is already the short name of --queue`
+	Z        bool `short:"z"`
+	Store    store
+}
+
+type db struct {
+	Host string
+}
+
+// store has a Set method, but not pflag.Value's, so it is still an option group
+type store struct {
+	Path string `shrot:"s"`     // want `unknown struct tag "shrot" on Path \(did you mean "short"\?\)`
+	Size int    `default:"abc"` // want `invalid default "abc" for Size: invalid syntax`
+}
+
+func (s *store) Set(key, value string) {}
+
+type dup struct {
+	Port int `defualt:"1"` // want `unknown struct tag "defualt" on Port \(did you mean "default"\?\)`
+}
+
+func (s *serve) RunE(ctx context.Context, args []string) error {
+	return nil
+}
+
+type cobraCmd struct{}
+
+func (c *cobraCmd) Run(cmd *cobra.Command, args []string) {}
+
+type broken struct{}
+
+func (b *broken) Run(s string) {}
+
+type none struct{}
+
+type withDupFlat struct {
+	Host string
+	Flat db `prefix:""` // want `duplicate flag --host`
+}
+
+func (w *withDupFlat) Run() {}
+
+type notStruct int
+
+func main() {
+	quack.BindCobra("app", &root{})
+	quack.MustBindUrfave("app", new(withDupFlat))
+	quack.Run(context.Background(), "app", notStruct(1), nil) // want `notStruct can't be a quack command: only structs can be commands`
+	var anything any = &root{}
+	quack.BindCobra("app", anything)
+}
+
+type ordered struct {
+	_ struct{} `category:"Management"`
+}
+
+func (o *ordered) OrderedSubCommands() []quack.Entry {
+	return []quack.Entry{
+		{Name: "serve", Command: &serve{}, Category: "Server"},
+		{"none", &none{}, ""}, // want `none is not a quack command: it has no Run, RunE, RunContext, SubCommands or OrderedSubCommands method`
+	}
+}
+
+func useOrdered() {
+	quack.BindCobra("ordered", &ordered{})
+}
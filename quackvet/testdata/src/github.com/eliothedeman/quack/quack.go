@@ -0,0 +1,18 @@
+// Package quack is a stub of the parts of quack the analyzer looks for.
+package quack
+
+import "context"
+
+type Map map[string]any
+
+func BindCobra(name string, root any, opts ...any) (any, error) { return nil, nil }
+
+func MustBindUrfave(name string, root any, opts ...any) any { return nil }
+
+func Run(ctx context.Context, name string, root any, args []string, opts ...any) error { return nil }
+
+type Entry struct {
+	Name     string
+	Command  any
+	Category string
+}
@@ -0,0 +1,4 @@
+// Package cobra is a stub of cobra for the command signatures.
+package cobra
+
+type Command struct{}
@@ -0,0 +1,288 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Run binds root and runs the command selected by args with quack's own parser, without going through
+// cobra or urfave/cli. args don't include the program name, so most programs pass os.Args[1:].
+//
+// Flags follow GNU conventions: --name value, --name=value, bundled short flags (-vx, -ofile),
+// flags mixed with positional arguments, and -- to end flag parsing.
+// Shell completion is only available with BindCobra and BindUrfave.
+func Run(ctx context.Context, name string, root any, args []string, opts ...BindOption) error {
+	rn, err := bind(name, root, opts)
+	if err != nil {
+		return err
+	}
+	return rn.execute(ctx, args)
+}
+
+// flagOptions are the named options that can be passed to c: its own and the globals of every group above it.
+func (c *node) flagOptions() []*option {
+	var options []*option
+	for i := range c.options {
+		if !c.options[i].Ignore {
+			options = append(options, &c.options[i])
+		}
+	}
+	for _, n := range c.lineage() {
+		if n.globals == nil {
+			continue
+		}
+		for i := range n.globals.options {
+			if !n.globals.options[i].Ignore {
+				options = append(options, &n.globals.options[i])
+			}
+		}
+	}
+	return options
+}
+
+// subcommand finds the direct subcommand of c with the given name or alias.
+func (c *node) subcommand(name string) *node {
+	for _, s := range c.subcommands {
+		if s.name == name || slices.Contains(s.aliases, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// hasName reports if the option can be passed as --name.
+func (o *option) hasName(name string) bool {
+	return o.Name == name || slices.Contains(o.Aliases, name)
+}
+
+// warnDeprecated writes a warning to w if c, or an option set on the command line, is deprecated.
+// The warnings read like the ones cobra prints itself.
+func (c *node) warnDeprecated(w io.Writer, isSet func(name string) bool) {
+	if c.deprecated != "" {
+		fmt.Fprintf(w, "Command %q is deprecated, %s\n", c.name, c.deprecated)
+	}
+	for _, o := range c.flagOptions() {
+		if o.Deprecated != "" && isSet(o.Name) {
+			fmt.Fprintf(w, "Flag --%s has been deprecated, %s\n", o.Name, o.Deprecated)
+		}
+	}
+}
+
+// argParser parses the command line for the native runtime.
+type argParser struct {
+	cmd        *node // the deepest command selected so far
+	args       []string
+	positional []string
+	set        map[string]bool
+	help       bool
+}
+
+// execute parses args, then runs the selected command the same way the framework backends do.
+func (c *node) execute(ctx context.Context, args []string) error {
+	p := &argParser{cmd: c, args: args, set: map[string]bool{}}
+	if err := p.parse(); err != nil {
+		return err
+	}
+	cmd := p.cmd
+	if p.help {
+		fmtHelp(c.cfg.out, cmd)
+		return nil
+	}
+	if cmd.isGroup && len(p.positional) > 0 {
+		return fmt.Errorf("unknown command %q for %q", p.positional[0], strings.Join(cmd.path(), " "))
+	}
+
+	isSet := func(name string) bool { return p.set[name] }
+	cmd.warnDeprecated(c.cfg.errOut, isSet)
+	ctx, err := cmd.prepare(ctx, p.positional, isSet)
+	if err != nil {
+		return err
+	}
+	if cmd.isGroup {
+		if err := cmd.run(ctx, p.positional); err != nil {
+			return err
+		}
+		fmtHelp(c.cfg.out, cmd)
+		return nil
+	}
+	if err := cmd.before(ctx); err != nil {
+		return err
+	}
+	return cmd.after(ctx, cmd.run(ctx, p.positional))
+}
+
+func (p *argParser) parse() error {
+	for len(p.args) > 0 {
+		arg := p.args[0]
+		p.args = p.args[1:]
+		switch {
+		case arg == "--":
+			p.positional = append(p.positional, p.args...)
+			p.args = nil
+		case strings.HasPrefix(arg, "--"):
+			if err := p.parseLong(arg[2:]); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			if err := p.parseShorts(arg); err != nil {
+				return err
+			}
+		default:
+			// subcommands are only recognized before the first positional argument
+			if sub := p.cmd.subcommand(arg); sub != nil && len(p.positional) == 0 {
+				if err := sub.load(); err != nil {
+					return err
+				}
+				p.cmd = sub
+				continue
+			}
+			p.positional = append(p.positional, arg)
+		}
+	}
+	return nil
+}
+
+// parseLong parses --name, --name=value or --name value.
+func (p *argParser) parseLong(arg string) error {
+	name, value, hasValue := strings.Cut(arg, "=")
+	if name == configFlag && p.cmd.cfg.configDefaults {
+		if !hasValue {
+			if len(p.args) == 0 {
+				return fmt.Errorf("flag needs an argument: --%s", name)
+			}
+			value, p.args = p.args[0], p.args[1:]
+		}
+		p.cmd.cfg.configPath = value
+		return nil
+	}
+
+	var o *option
+	var negate bool
+	for _, f := range p.cmd.flagOptions() {
+		if f.hasName(name) {
+			o = f
+			break
+		}
+	}
+	if positive, ok := strings.CutPrefix(name, negationPrefix); ok && o == nil {
+		for _, f := range p.cmd.flagOptions() {
+			if f.hasName(positive) && f.negatable() {
+				o, negate = f, true
+				break
+			}
+		}
+	}
+	if o == nil {
+		if name == "help" {
+			p.help = true
+			return nil
+		}
+		return fmt.Errorf("unknown flag: --%s", name)
+	}
+
+	switch {
+	case hasValue:
+	case o.Counter:
+		value = "+1"
+	case !o.takesValue():
+		value = "true"
+	case len(p.args) > 0:
+		value, p.args = p.args[0], p.args[1:]
+	default:
+		return fmt.Errorf("flag needs an argument: --%s", name)
+	}
+	if negate {
+		b, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid argument %q for %q flag: %w", value, "--"+name, err)
+		}
+		value = strconv.FormatBool(!b)
+	}
+	return p.setFlag(o, "--"+name, value)
+}
+
+// parseShorts parses bundled short flags such as -v, -vx, -ofile, -o file or -o=file.
+func (p *argParser) parseShorts(arg string) error {
+	shorts := arg[1:]
+	for len(shorts) > 0 {
+		short := shorts[:1]
+		shorts = shorts[1:]
+
+		var o *option
+		for _, f := range p.cmd.flagOptions() {
+			if f.Short == short {
+				o = f
+				break
+			}
+		}
+		if o == nil {
+			if short == "h" {
+				p.help = true
+				continue
+			}
+			return fmt.Errorf("unknown shorthand flag: '%s' in %s", short, arg)
+		}
+
+		var value string
+		switch {
+		case strings.HasPrefix(shorts, "="):
+			value, shorts = shorts[1:], ""
+		case o.Counter:
+			value = "+1"
+		case !o.takesValue():
+			value = "true"
+		case shorts != "":
+			value, shorts = shorts, ""
+		case len(p.args) > 0:
+			value, p.args = p.args[0], p.args[1:]
+		default:
+			return fmt.Errorf("flag needs an argument: '%s' in %s", short, arg)
+		}
+		if err := p.setFlag(o, "-"+short, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFlag assigns value to o.
+func (p *argParser) setFlag(o *option, flag, value string) error {
+	if err := o.setValue(value, !p.set[o.Name]); err != nil {
+		return fmt.Errorf("invalid argument %q for %q flag: %w", value, flag, err)
+	}
+	p.set[o.Name] = true
+	return nil
+}
+
+// isBool reports if the target is a flag that doesn't need a value.
+func isBool(v reflect.Value) bool {
+	return v.Kind() == reflect.Bool && !isCustom(v.Type())
+}
+
+// negationPrefix turns the name of a bool option into the name of the flag turning it off.
+const negationPrefix = "no-"
+
+// takesValue reports if the option needs a value when passed as a flag.
+// Bools are turned on and counters incremented without one.
+func (o *option) takesValue() bool {
+	return !isBool(o.Target) && !o.Counter
+}
+
+// longNames are the names the option can be passed as with --, including its aliases and negation.
+func (o *option) longNames() []string {
+	names := append([]string{o.Name}, o.Aliases...)
+	if o.negatable() {
+		names = append(names, negationPrefix+o.Name)
+	}
+	return names
+}
+
+// negatable reports if the option can be turned off with --no-<name>.
+func (o *option) negatable() bool {
+	return isBool(o.Target) && !o.NoNegate
+}
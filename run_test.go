@@ -0,0 +1,180 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nativeCmd struct {
+	Verbose bool          `short:"v"`
+	Extra   bool          `short:"x"`
+	Output  string        `short:"o" default:"out.txt"`
+	Tags    []string      `short:"t"`
+	Timeout time.Duration `default:"1s"`
+	Level   level
+	Source  string   `arg:"1" help:"file to read"`
+	Rest    []string `arg:"2"`
+	args    []string
+}
+
+func (n *nativeCmd) Run(args []string) {
+	n.args = args
+}
+
+type nativeRoot struct {
+	cmd *nativeCmd
+}
+
+func (n *nativeRoot) Help() string {
+	return "a tool"
+}
+
+func (n *nativeRoot) SubCommands() Map {
+	return Map{"copy": n.cmd}
+}
+
+func runNative(t *testing.T, args ...string) (*nativeCmd, error) {
+	cmd := &nativeCmd{}
+	err := Run(context.Background(), "app", &nativeRoot{cmd}, args, WithOutput(&bytes.Buffer{}))
+	return cmd, err
+}
+
+func TestRunFlags(t *testing.T) {
+	cmd, err := runNative(t, "copy", "--output=a.txt", "--timeout", "2m", "--level", "high", "src", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", cmd.Output)
+	assert.Equal(t, 2*time.Minute, cmd.Timeout)
+	assert.Equal(t, level(2), cmd.Level)
+	assert.Equal(t, "src", cmd.Source)
+	assert.Equal(t, []string{"a"}, cmd.Rest)
+}
+
+func TestRunDefaults(t *testing.T) {
+	cmd, err := runNative(t, "copy", "src", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, "out.txt", cmd.Output)
+	assert.Equal(t, time.Second, cmd.Timeout)
+	assert.False(t, cmd.Verbose)
+}
+
+func TestRunBundledShorts(t *testing.T) {
+	cmd, err := runNative(t, "copy", "-vxofile", "src", "a")
+	assert.Nil(t, err)
+	assert.True(t, cmd.Verbose)
+	assert.True(t, cmd.Extra)
+	assert.Equal(t, "file", cmd.Output)
+
+	cmd, err = runNative(t, "copy", "-vo", "file", "src", "a")
+	assert.Nil(t, err)
+	assert.True(t, cmd.Verbose)
+	assert.Equal(t, "file", cmd.Output)
+
+	cmd, err = runNative(t, "copy", "-o=file", "src", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, "file", cmd.Output)
+}
+
+func TestRunInterspersed(t *testing.T) {
+	cmd, err := runNative(t, "copy", "src", "-v", "b", "--output", "x", "c")
+	assert.Nil(t, err)
+	assert.True(t, cmd.Verbose)
+	assert.Equal(t, "x", cmd.Output)
+	assert.Equal(t, "src", cmd.Source)
+	assert.Equal(t, []string{"b", "c"}, cmd.Rest)
+	assert.Equal(t, []string{"src", "b", "c"}, cmd.args)
+}
+
+func TestRunTerminator(t *testing.T) {
+	cmd, err := runNative(t, "copy", "-v", "--", "-src", "--output", "copy")
+	assert.Nil(t, err)
+	assert.True(t, cmd.Verbose)
+	assert.Equal(t, "out.txt", cmd.Output)
+	assert.Equal(t, "-src", cmd.Source)
+	assert.Equal(t, []string{"--output", "copy"}, cmd.Rest)
+}
+
+func TestRunRepeated(t *testing.T) {
+	cmd, err := runNative(t, "copy", "-t", "a,b", "--tags", "c", "src", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, cmd.Tags)
+}
+
+func TestRunBoolValue(t *testing.T) {
+	cmd, err := runNative(t, "copy", "--verbose=false", "src", "a")
+	assert.Nil(t, err)
+	assert.False(t, cmd.Verbose)
+}
+
+func TestRunErrors(t *testing.T) {
+	for args, msg := range map[string][]string{
+		"unknown flag: --nope":                         {"copy", "--nope", "src"},
+		"unknown shorthand flag: 'q' in -vq":           {"copy", "-vq", "src"},
+		"flag needs an argument: --output":             {"copy", "src", "--output"},
+		"flag needs an argument: 'o' in -o":            {"copy", "src", "-o"},
+		`invalid argument "soon" for "--timeout" flag`: {"copy", "--timeout", "soon", "src"},
+		`unknown command "paste" for "app"`:            {"paste"},
+		"missing required positional argument: source": {"copy"},
+	} {
+		_, err := runNative(t, msg...)
+		if assert.NotNil(t, err, args) {
+			assert.Contains(t, err.Error(), args)
+		}
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	out := &bytes.Buffer{}
+	cmd := &nativeCmd{}
+	assert.Nil(t, Run(context.Background(), "app", &nativeRoot{cmd}, []string{"copy", "--help"}, WithOutput(out)))
+	assert.Nil(t, cmd.args)
+	help := out.String()
+	assert.Contains(t, help, "app copy [flags] <source> <rest>...")
+	assert.Contains(t, help, "file to read")
+	assert.Contains(t, help, "--output")
+	assert.Contains(t, help, "(default='out.txt')")
+	assert.Contains(t, help, "-h,")
+
+	out.Reset()
+	assert.Nil(t, Run(context.Background(), "app", &nativeRoot{cmd}, nil, WithOutput(out)))
+	assert.Contains(t, out.String(), "a tool")
+	assert.Contains(t, out.String(), "copy")
+}
+
+func TestRunFrameworkCommand(t *testing.T) {
+	err := Run(context.Background(), "app", &simpleCmd{}, nil)
+	assert.ErrorIs(t, err, ErrNotACommand)
+	assert.Contains(t, err.Error(), "cobra")
+}
+
+// TestRunDependencies checks that with the frameworks and config formats built out,
+// a program using Run or BindFlagSet links none of them.
+func TestRunDependencies(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(goCmd, "list", "-deps", "-tags", "quack_nocobra,quack_nourfave,quack_noyaml,quack_notoml", ".").CombinedOutput()
+	if !assert.NoError(t, err, string(out)) {
+		return
+	}
+	excluded := []string{
+		"github.com/spf13/cobra",
+		"github.com/spf13/pflag",
+		"github.com/urfave/cli",
+		"gopkg.in/yaml",
+		"github.com/BurntSushi/toml",
+	}
+	deps := strings.Fields(string(out))
+	assert.Contains(t, deps, "github.com/eliothedeman/quack")
+	for _, dep := range deps {
+		for _, e := range excluded {
+			assert.False(t, strings.HasPrefix(dep, e), "%s is linked", dep)
+		}
+	}
+}
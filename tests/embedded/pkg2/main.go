@@ -1,3 +1,5 @@
+//go:build !quack_nocobra
+
 package main
 
 import (
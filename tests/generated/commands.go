@@ -0,0 +1,93 @@
+// Package generated holds commands bound through code generated by quackgen,
+// to check the generated bindings behave like reflection.
+package generated
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eliothedeman/quack"
+)
+
+//go:generate go run github.com/eliothedeman/quack/cmd/quackgen -type=rootCmd,serveCmd,globals
+
+// Level is a named integer, bound like its underlying type.
+type Level int
+
+// Tags is a named slice, bound like its underlying type.
+type Tags []string
+
+// Mode parses itself.
+type Mode string
+
+func (m *Mode) Set(s string) error {
+	*m = Mode(s)
+	return nil
+}
+
+func (m Mode) String() string { return string(m) }
+func (m Mode) Type() string   { return "mode" }
+
+func (m Mode) Validate() error {
+	if m != "fast" && m != "safe" {
+		return fmt.Errorf("unknown mode %q", string(m))
+	}
+	return nil
+}
+
+type database struct {
+	Host string `default:"localhost"`
+	Port int    `default:"5432"`
+}
+
+func (d *database) Validate() error {
+	if d.Port < 1 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+type auth struct {
+	Token string `env:"GENERATED_TOKEN"`
+}
+
+type globals struct {
+	Debug   bool   `short:"d"`
+	Retries uint16 `default:"3"`
+}
+
+type rootCmd struct {
+	g     globals
+	serve serveCmd
+}
+
+func (r *rootCmd) Globals() any {
+	return &r.g
+}
+
+func (r *rootCmd) SubCommands() quack.Map {
+	return quack.Map{"serve": &r.serve}
+}
+
+type serveCmd struct {
+	auth
+	Addr    string        `short:"a" default:":8080" help:"address to listen on"`
+	Level   Level         `short:"l"`
+	Timeout time.Duration `default:"5s"`
+	Tags    Tags
+	Weights []float64
+	Verbose bool `short:"v"`
+	Mode    Mode `default:"fast"`
+	Ratio   float32
+	Small   int8
+	DB      database
+	Skip    string `ignore:""`
+	Source  string `arg:"1"`
+	Counts  []int  `arg:"2"`
+	Global  *globals
+	Workers *int
+	Pick    *Mode
+}
+
+func (s *serveCmd) Run([]string) {}
@@ -0,0 +1,78 @@
+//go:build !quack_nocobra
+
+package generated
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eliothedeman/quack"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ quack.Generated = (*serveCmd)(nil)
+
+// TestGeneratedParity runs the same command lines through the generated bindings and through reflection,
+// and expects the same fields, output and errors.
+func TestGeneratedParity(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		out  string
+	}{
+		{name: "defaults", args: []string{"serve", "src"}},
+		{name: "every option", args: []string{
+			"serve", "-a", ":9090", "-l", "3", "--timeout", "1m", "--tags", "a,b", "--tags", "c",
+			"--weights", "1.5", "-v", "--mode", "safe", "--ratio", "0.5", "--small", "7",
+			"--db-host", "db", "--db-port", "1", "-d", "--retries", "5", "--token", "secret",
+			"src", "1", "2", "3",
+		}},
+		{name: "env", args: []string{"serve", "src"}, env: map[string]string{"GENERATED_TOKEN": "from-env"}},
+		{name: "overflow", args: []string{"serve", "--small", "300", "src"}},
+		{name: "invalid positional", args: []string{"serve", "src", "x"}},
+		{name: "missing positional", args: []string{"serve"}},
+		{name: "option validation", args: []string{"serve", "--mode", "slow", "src"}},
+		{name: "group validation", args: []string{"serve", "--db-port", "0", "src"}},
+		{name: "pointers", args: []string{"serve", "--workers", "0", "--pick", "safe", "src"}},
+		{name: "pointer validation", args: []string{"serve", "--pick", "slow", "src"}},
+		{name: "help", args: []string{"serve", "--help"}, out: "--db-host"},
+		{name: "group help", args: []string{"--help"}, out: "serve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			generated, generatedOut, generatedErr := execute(t, tt.args)
+			reflected, reflectedOut, reflectedErr := execute(t, tt.args, quack.WithReflection())
+
+			assert.Equal(t, reflected.g, generated.g)
+			// globals are injected when the command runs
+			assert.Equal(t, reflected.serve.Global != nil, generated.serve.Global != nil)
+			if generated.serve.Global != nil {
+				assert.Same(t, &generated.g, generated.serve.Global)
+			}
+			reflected.serve.Global, generated.serve.Global = nil, nil
+			assert.Equal(t, reflected.serve, generated.serve)
+			assert.Equal(t, reflectedOut, generatedOut)
+			assert.Contains(t, generatedOut, tt.out)
+			assert.Equal(t, reflectedErr, generatedErr)
+		})
+	}
+}
+
+func execute(t *testing.T, args []string, opts ...quack.BindOption) (*rootCmd, string, error) {
+	t.Helper()
+	root := &rootCmd{}
+	cmd, err := quack.BindCobra("app", root, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err = cmd.Execute()
+	return root, out.String(), err
+}
@@ -0,0 +1,64 @@
+// Code generated by quackgen. DO NOT EDIT.
+
+package generated
+
+import "fmt"
+
+// QuackFields returns a pointer to the field of each option of rootCmd.
+func (c *rootCmd) QuackFields() map[string]any {
+	return map[string]any{}
+}
+
+// QuackValidate validates the options and option groups of rootCmd.
+func (c *rootCmd) QuackValidate() error {
+	return nil
+}
+
+// QuackFields returns a pointer to the field of each option of serveCmd.
+func (c *serveCmd) QuackFields() map[string]any {
+	return map[string]any{
+		"token":   &c.auth.Token,
+		"addr":    &c.Addr,
+		"level":   (*int)(&c.Level),
+		"timeout": &c.Timeout,
+		"tags":    (*[]string)(&c.Tags),
+		"weights": &c.Weights,
+		"verbose": &c.Verbose,
+		"mode":    &c.Mode,
+		"ratio":   &c.Ratio,
+		"small":   &c.Small,
+		"db-host": &c.DB.Host,
+		"db-port": &c.DB.Port,
+		"source":  &c.Source,
+		"counts":  &c.Counts,
+	}
+}
+
+// QuackValidate validates the options and option groups of serveCmd.
+func (c *serveCmd) QuackValidate() error {
+	if err := c.Mode.Validate(); err != nil {
+		return fmt.Errorf("validation failed for option mode: %w", err)
+	}
+	if c.Pick != nil {
+		if err := c.Pick.Validate(); err != nil {
+			return fmt.Errorf("validation failed for option pick: %w", err)
+		}
+	}
+	if err := c.DB.Validate(); err != nil {
+		return fmt.Errorf("validation failed for database: %w", err)
+	}
+	return nil
+}
+
+// QuackFields returns a pointer to the field of each option of globals.
+func (c *globals) QuackFields() map[string]any {
+	return map[string]any{
+		"debug":   &c.Debug,
+		"retries": &c.Retries,
+	}
+}
+
+// QuackValidate validates the options and option groups of globals.
+func (c *globals) QuackValidate() error {
+	return nil
+}
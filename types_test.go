@@ -0,0 +1,163 @@
+package quack
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// allTypesCmd has a field of every type the backends are expected to support.
+type allTypesCmd struct {
+	Bool     bool
+	Int      int
+	Int8     int8
+	Int16    int16
+	Int32    int32
+	Int64    int64
+	Uint     uint
+	Uint8    uint8
+	Uint16   uint16
+	Uint32   uint32
+	Uint64   uint64
+	Float32  float32
+	Float64  float64
+	String   string
+	Duration time.Duration
+	Time     time.Time
+
+	Strings  []string
+	Ints     []int
+	Int32s   []int32
+	Int64s   []int64
+	Uints    []uint
+	Float32s []float32
+	Float64s []float64
+	Bools    []bool
+
+	Size      ByteSize
+	Port      Port
+	Sizes     []ByteSize
+	Ports     []Port
+	HostPorts []HostPort
+	Links     []URL
+}
+
+func (a *allTypesCmd) Run([]string) {
+}
+
+// TestTypes runs every supported type through every backend, so they can't drift apart.
+func TestTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want allTypesCmd
+	}{
+		{
+			name: "unset",
+			want: allTypesCmd{},
+		},
+		{
+			name: "scalars",
+			args: []string{
+				"--bool",
+				"--int", "-1",
+				"--int-8", "-8",
+				"--int-16", "-16",
+				"--int-32", "-32",
+				"--int-64", "-64",
+				"--uint", "1",
+				"--uint-8", "8",
+				"--uint-16", "16",
+				"--uint-32", "32",
+				"--uint-64", "64",
+				"--float-32", "3.5",
+				"--float-64", "6.25",
+				"--string", "s",
+				"--duration", "1m30s",
+				"--time", "2024-05-06T07:08:09Z",
+				"--size", "4KiB",
+				"--port", "8080",
+			},
+			want: allTypesCmd{
+				Bool:     true,
+				Int:      -1,
+				Int8:     -8,
+				Int16:    -16,
+				Int32:    -32,
+				Int64:    -64,
+				Uint:     1,
+				Uint8:    8,
+				Uint16:   16,
+				Uint32:   32,
+				Uint64:   64,
+				Float32:  3.5,
+				Float64:  6.25,
+				String:   "s",
+				Duration: 90 * time.Second,
+				Time:     time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+				Size:     4 << 10,
+				Port:     8080,
+			},
+		},
+		{
+			name: "slices",
+			args: []string{
+				"--strings", "a", "--strings", "b",
+				"--ints", "1", "--ints", "2",
+				"--int-32-s", "3",
+				"--int-64-s", "4",
+				"--uints", "5",
+				"--float-32-s", "1.5",
+				"--float-64-s", "2.5", "--float-64-s", "3.5",
+				"--bools", "true", "--bools", "false",
+				"--sizes", "1k", "--sizes", "2MiB",
+				"--ports", "80", "--ports", "443",
+				"--host-ports", "a:1", "--host-ports", ":2",
+				"--links", "https://a.example",
+			},
+			want: allTypesCmd{
+				Strings:   []string{"a", "b"},
+				Ints:      []int{1, 2},
+				Int32s:    []int32{3},
+				Int64s:    []int64{4},
+				Uints:     []uint{5},
+				Float32s:  []float32{1.5},
+				Float64s:  []float64{2.5, 3.5},
+				Bools:     []bool{true, false},
+				Sizes:     []ByteSize{1000, 2 << 20},
+				Ports:     []Port{80, 443},
+				HostPorts: []HostPort{{"a", 1}, {"", 2}},
+				Links:     []URL{{url.URL{Scheme: "https", Host: "a.example"}}},
+			},
+		},
+	}
+	for _, test := range tests {
+		for backend, run := range backends {
+			t.Run(test.name+"/"+backend, func(t *testing.T) {
+				cmd := new(allTypesCmd)
+				assert.Nil(t, run(t, cmd, test.args...))
+				assert.Equal(t, test.want, *cmd)
+			})
+		}
+	}
+}
+
+func TestTypesInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"--int-8", "300"},
+		{"--uint", "-1"},
+		{"--duration", "soon"},
+		{"--time", "yesterday"},
+		{"--bools", "maybe"},
+		{"--ports", "0"},
+		{"--sizes", "lots"},
+	} {
+		for backend, run := range backends {
+			t.Run(args[0]+"/"+backend, func(t *testing.T) {
+				assert.NotNil(t, run(t, new(allTypesCmd), args...))
+			})
+		}
+	}
+}
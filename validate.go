@@ -0,0 +1,196 @@
+package quack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// bind builds the node tree for root and validates it.
+func bind(name string, root any, opts []BindOption) (*node, error) {
+	rn := &node{cfg: newBindConfig(opts)}
+	if err := rn.fromStruct(name, root); err != nil {
+		return nil, err
+	}
+	if err := rn.validate(); err != nil {
+		return nil, err
+	}
+	return rn, nil
+}
+
+// problem records a mistake in the definition of c. Binding carries on so every mistake can be reported at once.
+func (c *node) problem(err error) {
+	c.problems = append(c.problems, err)
+}
+
+// walk calls f for c and every command below it.
+func (c *node) walk(f func(*node)) {
+	f(c)
+	for _, s := range c.subcommands {
+		s.walk(f)
+	}
+}
+
+// validate reports every mistake in the options of c and its subcommands as a single error.
+func (c *node) validate() error {
+	var errs []error
+	c.walk(func(n *node) {
+		errs = append(errs, n.checkOptions()...)
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w:\n%w", ErrInvalidOption, errors.Join(errs...))
+}
+
+// checkOptions finds the mistakes in the options declared by c.
+func (c *node) checkOptions() []error {
+	var errs []error
+	path := strings.Join(c.path(), " ")
+	add := func(err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+
+	own := append([]option(nil), c.options...)
+	problems := c.problems
+	if c.globals != nil {
+		own = append(own, c.globals.options...)
+		problems = append(problems, c.globals.problems...)
+	}
+	for _, p := range problems {
+		add(p)
+	}
+	for _, o := range append(own, c.positionalOptions...) {
+		if o.Ignore {
+			continue
+		}
+		if !supportedType(o.Target.Type()) {
+			t := o.Target.Type()
+			if o.pointer.IsValid() {
+				t = o.pointer.Type()
+			}
+			add(fmt.Errorf("%w %v for %s", ErrInvalidType, t, o.Name))
+		} else if o.pointer.IsValid() && isRepeated(o.Target) {
+			add(fmt.Errorf("pointer option %s must point to a single value, got %v", o.Name, o.pointer.Type()))
+		}
+		if t := o.Target.Type(); len(o.Choices) > 0 && t.Kind() != reflect.String &&
+			(t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.String) {
+			add(fmt.Errorf("choices of %s need a string or a slice of strings, got %v", o.Name, t))
+		}
+		for _, err := range o.checkValueTags() {
+			add(err)
+		}
+		if force := c.lookupOption(o.Overwrite); o.Overwrite != "" && (force == nil || force.Target.Kind() != reflect.Bool) {
+			add(fmt.Errorf("overwrite of %s must name a bool option, got %q", o.Name, o.Overwrite))
+		}
+		if d := o.Duplicates; d != "" && d != duplicatesLast && d != duplicatesFirst && d != duplicatesError {
+			add(fmt.Errorf("duplicates of %s must be %s, %s or %s, got %q", o.Name, duplicatesLast, duplicatesFirst, duplicatesError, d))
+		}
+		if o.Counter && o.Target.Type() != reflect.TypeOf(0) {
+			add(fmt.Errorf("counter %s must be an int, got %v", o.Name, o.Target.Type()))
+		}
+		if o.Short != "" && utf8.RuneCountInString(o.Short) != 1 {
+			add(fmt.Errorf("short name %q of --%s must be a single character", o.Short, o.Name))
+		}
+	}
+
+	// names already taken by the framework, or the globals of a group above c, and the flag they belong to.
+	// urfave and the flag package keep long and short names together, so one letter long names
+	// can't be another option's short name either.
+	longs := map[string]string{"help": "--help"}
+	shorts := map[string]string{"h": "--help"}
+	if c.cfg.configDefaults {
+		longs[configFlag] = "--" + configFlag
+	}
+	for _, n := range c.lineage()[:len(c.lineage())-1] {
+		if n.globals == nil {
+			continue
+		}
+		for _, o := range n.globals.options {
+			if !o.Ignore {
+				for _, name := range o.longNames() {
+					longs[name] = "--" + o.Name
+				}
+				if o.Short != "" {
+					shorts[o.Short] = "--" + o.Name
+				}
+			}
+		}
+	}
+	var named []option
+	if c.globals != nil {
+		named = append(named, c.globals.options...)
+	}
+	named = append(named, c.options...)
+	for _, o := range named {
+		if o.Ignore {
+			continue
+		}
+		flag := "--" + o.Name
+		for _, name := range o.longNames() {
+			if _, ok := longs[name]; ok {
+				add(fmt.Errorf("duplicate flag --%s", name))
+			} else if other, ok := shorts[name]; ok && other != flag {
+				add(fmt.Errorf("flag --%s is already the short name of %s", name, other))
+			}
+			longs[name] = flag
+		}
+		if o.Short == "" {
+			continue
+		}
+		if other, ok := shorts[o.Short]; ok {
+			add(fmt.Errorf("short name -%s of --%s is already used by %s", o.Short, o.Name, other))
+		} else if other, ok := longs[o.Short]; ok && other != flag {
+			add(fmt.Errorf("short name -%s of --%s is already used by %s", o.Short, o.Name, other))
+		}
+		shorts[o.Short] = flag
+	}
+
+	// positional arguments are numbered from 1, and only the last one can be repeated
+	var positional []option
+	for _, o := range c.positionalOptions {
+		if !o.Ignore {
+			positional = append(positional, o)
+		}
+	}
+	for i, o := range positional {
+		switch {
+		case i > 0 && o.Arg == positional[i-1].Arg:
+			add(fmt.Errorf("%s and %s are both positional argument %d", positional[i-1].Name, o.Name, o.Arg))
+		case o.Arg != i+1 && (i == 0 || o.Arg != positional[i-1].Arg+1):
+			add(fmt.Errorf("positional argument %s is %d, expected %d", o.Name, o.Arg, i+1))
+		}
+		if isRepeated(o.Target) && i < len(positional)-1 {
+			add(fmt.Errorf("repeated positional argument %s must be the last one", o.Name))
+		}
+	}
+	return errs
+}
+
+// supportedType reports if every backend can parse options of type t.
+func supportedType(t reflect.Type) bool {
+	if isCustom(t) {
+		return true
+	}
+	if t.Kind() == reflect.Slice {
+		return isCustomElem(t.Elem()) || isBasicKind(t.Elem().Kind())
+	}
+	if t.Kind() == reflect.Map {
+		return (isCustom(t.Key()) || isBasicKind(t.Key().Kind())) &&
+			(isCustom(t.Elem()) || isBasicKind(t.Elem().Kind()))
+	}
+	return isBasicKind(t.Kind())
+}
+
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
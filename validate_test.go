@@ -0,0 +1,93 @@
+package quack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type badTagsCmd struct {
+	Port    int    `short:"p"`
+	Path    string `short:"p"`
+	Hidden  bool   `short:"h"`
+	Name    string `short:"nm"`
+	Count   int    `default:"many"`
+	Complex complex64
+	Files   []string  `arg:"1"`
+	Target  string    `arg:"3"`
+	Other   string    `arg:"3"`
+	Bad     string    `arg:"zero"`
+	Dup     dupConfig `prefix:""`
+	X       int
+	Y       int  `short:"x"`
+	Queue   int  `short:"q"`
+	Quiet   bool `alias:"q"`
+}
+
+type dupConfig struct {
+	Port int
+}
+
+func (b *badTagsCmd) Run([]string) {
+}
+
+func (b *badTagsCmd) Globals() any {
+	return &globalOpts{}
+}
+
+type badLeafCmd struct {
+	Verbose bool
+	Host    string `short:"v"`
+	Help    bool
+}
+
+func (b *badLeafCmd) Run([]string) {
+}
+
+type badRoot struct {
+	Opts globalOpts
+}
+
+func (b *badRoot) Globals() any {
+	return &b.Opts
+}
+
+func (b *badRoot) SubCommands() Map {
+	return Map{"leaf": &badLeafCmd{}}
+}
+
+func TestValidate(t *testing.T) {
+	for backend, run := range backends {
+		t.Run(backend, func(t *testing.T) {
+			err := run(t, new(badTagsCmd))
+			assert.ErrorIs(t, err, ErrInvalidOption)
+			assert.ErrorIs(t, err, ErrInvalidType)
+			for _, msg := range []string{
+				"app: invalid arg value 'zero' for field Bad: must be a positive integer",
+				`app: invalid default value "many" for count`,
+				"app: invlaid type complex64 for complex",
+				"app: short name -p of --path is already used by --port",
+				"app: short name -h of --hidden is already used by --help",
+				`app: short name "nm" of --name must be a single character`,
+				"app: positional argument target is 3, expected 2",
+				"app: target and other are both positional argument 3",
+				"app: repeated positional argument files must be the last one",
+				"app: duplicate flag --port",
+				"app: short name -x of --y is already used by --x",
+				"app: flag --q is already the short name of --queue",
+			} {
+				assert.ErrorContains(t, err, msg)
+			}
+
+			err = run(t, new(badRoot))
+			assert.ErrorIs(t, err, ErrInvalidOption)
+			for _, msg := range []string{
+				"app leaf: duplicate flag --verbose",
+				"app leaf: short name -v of --host is already used by --verbose",
+				"app leaf: duplicate flag --help",
+			} {
+				assert.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
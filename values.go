@@ -0,0 +1,95 @@
+package quack
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	urlType  = reflect.TypeOf(URL{})
+)
+
+// typeNames are the names shown in help for types from the standard library that parse themselves,
+// where the name of the type alone would be unclear.
+var typeNames = map[reflect.Type]string{
+	reflect.TypeOf(netip.Addr{}):     "ip",
+	reflect.TypeOf(netip.Prefix{}):   "cidr",
+	reflect.TypeOf(netip.AddrPort{}): "ip:port",
+}
+
+// isCustomElem reports if the elements of a slice parse themselves, either directly or through a pointer
+// such as *regexp.Regexp.
+func isCustomElem(t reflect.Type) bool {
+	return isCustom(t) || (t.Kind() == reflect.Pointer && isCustom(t.Elem()))
+}
+
+// elemType is the type of the values the option takes, the element type for slices.
+func (o *option) elemType() reflect.Type {
+	if isSlice(o.Target) {
+		return o.Target.Type().Elem()
+	}
+	return o.Target.Type()
+}
+
+// parsedByOption reports if values must be parsed by the option itself, rather than by the flag
+// libraries or customValue, to check its choices or follow its `layout`, `scheme`, `min` and `base` tags.
+func (o *option) parsedByOption() bool {
+	return len(o.Choices) > 0 || o.Layout != "" || len(o.Schemes) > 0 || o.Min > 0 || o.elemType() == pathWithinType
+}
+
+// parseElem parses a single value into v, the target or an element of it, following the tags that
+// change how values of its type are read.
+func (o *option) parseElem(v reflect.Value, value string) error {
+	if o.Layout != "" && v.Type() == timeType {
+		t, err := time.Parse(o.Layout, value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if err := parseScalar(v, value); err != nil {
+		return err
+	}
+	if u, ok := v.Interface().(URL); ok && len(o.Schemes) > 0 && !slices.Contains(o.Schemes, u.Scheme) {
+		return fmt.Errorf("scheme %q of %s is not one of %s", u.Scheme, value, strings.Join(o.Schemes, ", "))
+	}
+	return o.checkPath(v)
+}
+
+// formatElem renders v, the target or an element of it, so parseElem reads it back.
+func (o *option) formatElem(v reflect.Value) string {
+	if o.Layout != "" && v.Type() == timeType {
+		if t := v.Interface().(time.Time); !t.IsZero() {
+			return t.Format(o.Layout)
+		}
+		return ""
+	}
+	return formatScalar(v)
+}
+
+// checkValueTags reports tags that don't apply to the type of the option.
+func (o *option) checkValueTags() []error {
+	var errs []error
+	if o.Layout != "" && o.elemType() != timeType {
+		errs = append(errs, fmt.Errorf("layout of %s needs a time.Time, got %v", o.Name, o.Target.Type()))
+	}
+	if len(o.Schemes) > 0 && o.elemType() != urlType {
+		errs = append(errs, fmt.Errorf("scheme of %s needs a quack.URL, got %v", o.Name, o.Target.Type()))
+	}
+	if o.Min > 0 && o.elemType() != globType {
+		errs = append(errs, fmt.Errorf("min of %s needs a quack.Glob, got %v", o.Name, o.Target.Type()))
+	}
+	if o.Base != "" && o.elemType() != pathWithinType {
+		errs = append(errs, fmt.Errorf("base of %s needs a quack.PathWithin, got %v", o.Name, o.Target.Type()))
+	}
+	if o.Overwrite != "" && o.elemType() != newFileType {
+		errs = append(errs, fmt.Errorf("overwrite of %s needs a quack.NewFilePath, got %v", o.Name, o.Target.Type()))
+	}
+	return errs
+}
@@ -0,0 +1,175 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fetchCmd struct {
+	Limit   ByteSize         `default:"10MiB"`
+	Chunks  []ByteSize       `short:"c"`
+	Proxy   URL              `scheme:"http,https"`
+	Mirrors []URL            `scheme:"https"`
+	Bind    net.IP           `default:"127.0.0.1"`
+	Peers   []netip.Addr     `short:"p"`
+	Allow   []netip.Prefix   `default:"10.0.0.0/8"`
+	Match   *regexp.Regexp   `short:"m"`
+	Skip    []*regexp.Regexp `short:"s"`
+	Since   time.Time        `layout:"2006-01-02"`
+	Until   time.Time
+	Port    Port       `default:"8080"`
+	Listen  HostPort   `default:":9090"`
+	Source  URL        `arg:"1"`
+	Dests   []HostPort `arg:"2"`
+}
+
+func (f *fetchCmd) Run([]string) {}
+
+func TestValues(t *testing.T) {
+	for name, run := range backends {
+		t.Run(name, func(t *testing.T) {
+			cmd := &fetchCmd{}
+			assert.NoError(t, run(t, cmd,
+				"--limit", "1.5GB", "-c", "64k", "--chunks", "2MiB",
+				"--proxy", "http://proxy:3128", "--mirrors", "https://a.example", "--mirrors", "https://b.example",
+				"--bind", "::1", "-p", "10.0.0.1", "-p", "fe80::1",
+				"-m", "^v[0-9]+$", "-s", "a,b", "-s", `\.tmp$`,
+				"--since", "2024-02-29", "--until", "2024-03-01T10:00:00Z",
+				"--port", "443", "--listen", "[::1]:8443",
+				"s3://bucket/key", "example.com:22", ":2222",
+			))
+			assert.Equal(t, ByteSize(1_500_000_000), cmd.Limit)
+			assert.Equal(t, []ByteSize{64_000, 2 << 20}, cmd.Chunks)
+			assert.Equal(t, "proxy:3128", cmd.Proxy.Host)
+			require.Len(t, cmd.Mirrors, 2)
+			assert.Equal(t, "b.example", cmd.Mirrors[1].Host)
+			assert.Equal(t, net.ParseIP("::1"), cmd.Bind)
+			assert.Equal(t, []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fe80::1")}, cmd.Peers)
+			assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, cmd.Allow)
+			require.NotNil(t, cmd.Match)
+			assert.True(t, cmd.Match.MatchString("v12"))
+			require.Len(t, cmd.Skip, 2)
+			assert.Equal(t, "a,b", cmd.Skip[0].String())
+			assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), cmd.Since)
+			assert.True(t, cmd.Until.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)))
+			assert.Equal(t, Port(443), cmd.Port)
+			assert.Equal(t, HostPort{"::1", 8443}, cmd.Listen)
+			assert.Equal(t, "s3", cmd.Source.Scheme)
+			assert.Equal(t, []HostPort{{"example.com", 22}, {"", 2222}}, cmd.Dests)
+
+			cmd = &fetchCmd{}
+			assert.NoError(t, run(t, cmd, "file:///tmp/x", ":1"))
+			assert.Equal(t, ByteSize(10<<20), cmd.Limit)
+			assert.Equal(t, net.ParseIP("127.0.0.1").String(), cmd.Bind.String())
+			assert.Equal(t, Port(8080), cmd.Port)
+			assert.Equal(t, HostPort{Port: 9090}, cmd.Listen)
+			assert.Nil(t, cmd.Match)
+			assert.Equal(t, "/tmp/x", cmd.Source.Path)
+
+			for _, tt := range []struct {
+				args []string
+				err  string
+			}{
+				{[]string{"--limit", "10XB"}, `unknown unit "XB" in size "10XB"`},
+				{[]string{"--proxy", "ftp://proxy"}, `scheme "ftp" of ftp://proxy is not one of http, https`},
+				{[]string{"--mirrors", "http://a.example"}, `scheme "http" of http://a.example is not one of https`},
+				{[]string{"--since", "29/02/2024"}, `cannot parse "29/02/2024" as "2006"`},
+				{[]string{"--port", "70000"}, "port 70000 is out of range 1-65535"},
+				{[]string{"--port", "0"}, "port 0 is out of range 1-65535"},
+				{[]string{"--listen", "localhost"}, "missing port in address"},
+				{[]string{"-p", "10.0.0.256"}, "10.0.0.256"},
+				{[]string{"-m", "("}, "missing closing )"},
+				{[]string{"--allow", "10.0.0.0/33"}, "10.0.0.0/33"},
+				{[]string{"bucket/key"}, `"bucket/key" is missing a scheme`},
+				{[]string{"s3://b", "example.com"}, "missing port in address"},
+			} {
+				assert.ErrorContains(t, run(t, &fetchCmd{}, append(tt.args, "s3://b")...), tt.err, tt.args)
+			}
+		})
+	}
+}
+
+type uploadCmd struct {
+	Limit ByteSize  `env:"VALUES_TEST_LIMIT"`
+	Since time.Time `layout:"2006-01-02"`
+	Peers []netip.Addr
+	Port  Port
+}
+
+func (u *uploadCmd) Run([]string) {}
+
+func TestValuesEnvAndConfig(t *testing.T) {
+	t.Setenv("VALUES_TEST_LIMIT", "512KiB")
+	path := writeConfig(t, t.TempDir(), "app.json", `{"since": "2023-12-31", "peers": ["192.168.1.1"], "port": 22}`)
+	for name, run := range backendsWith(WithConfigFiles(path)) {
+		t.Run(name, func(t *testing.T) {
+			cmd := &uploadCmd{}
+			assert.NoError(t, run(t, cmd))
+			assert.Equal(t, ByteSize(512<<10), cmd.Limit)
+			assert.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), cmd.Since)
+			assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.168.1.1")}, cmd.Peers)
+			assert.Equal(t, Port(22), cmd.Port)
+		})
+	}
+}
+
+func TestValueTagsInvalid(t *testing.T) {
+	_, err := bind("app", &struct {
+		simpleCmd
+		Since  string    `layout:"2006-01-02"`
+		Proxy  string    `scheme:"http"`
+		Before time.Time `layout:"2006-01-02" default:"yesterday"`
+	}{}, nil)
+	require.ErrorIs(t, err, ErrInvalidOption)
+	assert.ErrorContains(t, err, "layout of since needs a time.Time, got string")
+	assert.ErrorContains(t, err, "scheme of proxy needs a quack.URL, got string")
+	assert.ErrorContains(t, err, `invalid default value "yesterday" for before`)
+}
+
+func TestValuesHelp(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, Run(context.Background(), "app", &fetchCmd{}, []string{"--help"}, WithOutput(&out)))
+	for _, re := range []string{
+		`--limit +size +\(default=10MiB\)`,
+		`--proxy +url`,
+		`--bind +ip +\(default=127.0.0.1\)`,
+		`--peers +ipSlice`,
+		`--allow +cidrSlice +\(default=10.0.0.0/8\)`,
+		`--match +regexp`,
+		`--skip +regexpSlice`,
+		`--since +2006-01-02`,
+		`--until +time`,
+		`--port +port +\(default=8080\)`,
+		`--listen +host:port +\(default=:9090\)`,
+	} {
+		assert.Regexp(t, re, out.String())
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	for in, want := range map[string]ByteSize{
+		"0": 0, "512": 512, "512B": 512, "1k": 1000, "1KB": 1000, "1Ki": 1024, "1kib": 1024,
+		"1.5MiB": 3 << 19, "2G": 2e9, "1TiB": 1 << 40, "3 PB": 3e15,
+	} {
+		var b ByteSize
+		assert.NoError(t, b.Set(in), in)
+		assert.Equal(t, want, b, in)
+	}
+	for _, in := range []string{"", "MiB", "-1", "1.2.3", "10 bytes", "9000PiB"} {
+		var b ByteSize
+		assert.Error(t, b.Set(in), in)
+	}
+	for b, want := range map[ByteSize]string{
+		0: "0B", 1000: "1KB", 1024: "1KiB", 1536: "1536B", 3 << 19: "1536KiB", 1e9: "1GB", 1 << 30: "1GiB",
+	} {
+		assert.Equal(t, want, b.String())
+	}
+}